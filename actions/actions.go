@@ -9,21 +9,55 @@ import (
 
 type Load struct{}
 
+// ReportError shows a notification and adds the error to the log (see App.Fail)
+type ReportError struct {
+	Source   models.ErrorSource
+	Severity models.Severity
+	Message  string
+}
+
+// DismissError closes the notification of error ID, or all notifications if ID is 0
+type DismissError struct{ ID int }
+
+// ClearErrors empties the error log
+type ClearErrors struct{}
+
 type ConsoleFirstWrite struct{}
 type ConsoleToggleClick struct{}
+type ConsoleClear struct{}
+type ConsoleCopy struct{}
+
+// ConsoleSearch shows only the lines of the console that contain Query
+type ConsoleSearch struct{ Query string }
+
+// StdinSend queues a line of input for the stdin of the running program
+type StdinSend struct{ Text string }
+
+// StdinClose ends the input, so the next read of stdin after the queue is empty returns io.EOF
+type StdinClose struct{}
+
+// ViewFile shows a file written by the program in the files modal
+type ViewFile struct{ Name string }
+
+// DownloadFile downloads a file written by the program
+type DownloadFile struct{ Name string }
 type MinifyToggleClick struct{}
 
 type ShowAllDepsChange struct{ State bool }
 
 type ChangeSplit struct{ Sizes []float64 }
 type ChangeFile struct {
-	Path string
-	Name string
+	Path   string
+	Name   string
+	Line   int // Line to move the cursor to (optional)
+	Column int // Column to move the cursor to (optional)
 }
 
 type LoadSource struct {
 	Source         map[string]map[string]string
 	Tags           []string
+	Run            []models.RunConfig
+	RunConfig      string // Name of the selected run configuration
 	CurrentPackage string
 	CurrentFile    string
 	Save           bool // Save directly after loading? false during initialising, true for load package.
@@ -44,6 +78,22 @@ type ModalClose struct{ Modal models.Modal }
 type DownloadClick struct{}
 type BuildTags struct{ Tags []string }
 
+// RunConfigs replaces the run configurations, and selects the configuration named Selected
+type RunConfigs struct {
+	Configs  []models.RunConfig
+	Selected string
+}
+
+// SelectRunConfig selects the run configuration used by Run. Name is empty to run the current package.
+type SelectRunConfig struct{ Name string }
+
+// ChangeServer changes the compile server. Url is the base URL of a self-hosted server, or empty to
+// use the default servers.
+type ChangeServer struct{ Url string }
+
+// ModuleChanged is dispatched when the module path or requirements in go.mod change
+type ModuleChanged struct{}
+
 type AddFile struct{ Name string }
 type AddPackage struct{ Path string }
 type DeleteFile struct{ Name string }
@@ -51,9 +101,83 @@ type RemovePackage struct{ Path string }
 
 type FormatCode struct{ Then flux.ActionInterface }
 
+// Undo reverts the last change to the project
+type Undo struct{}
+
+// Redo re-applies the last change reverted by Undo
+type Redo struct{}
+
+// RestoreSource replaces the entire source, e.g. when a change is undone
+type RestoreSource struct {
+	Source         map[string]map[string]string
+	CurrentPackage string
+	CurrentFile    string
+	Reset          bool // Reset clears the undo history (e.g. when switching workspace)
+}
+
+// CreateWorkspace creates a workspace with the default source and switches to it
+type CreateWorkspace struct{ Name string }
+
+// SwitchWorkspace saves the current workspace and loads another
+type SwitchWorkspace struct{ ID string }
+type RenameWorkspace struct{ ID, Name string }
+
+// DuplicateWorkspace copies a workspace and switches to the copy
+type DuplicateWorkspace struct{ ID, Name string }
+
+// DeleteWorkspace deletes a workspace. If it's the current workspace, another is loaded.
+type DeleteWorkspace struct{ ID string }
+
+// WorkspaceChanged is dispatched when another tab saves workspace ID. An empty ID means the list
+// of workspaces changed. Revision is the revision that was saved, or zero if the workspace was
+// deleted.
+type WorkspaceChanged struct {
+	ID       string
+	Revision int
+}
+
+// ReloadWorkspace discards the changes in this tab and loads the current workspace from storage
+type ReloadWorkspace struct{}
+
 // CompileStart compiles the app and injects the js into the iframe
 type CompileStart struct{}
 
+// CompileComplete is dispatched when a compile started by CompileStart or TestStart finishes. If
+// Err is nil, the main package Path is run with Deps, or Wasm if it was compiled to WebAssembly.
+type CompileComplete struct {
+	Path  string
+	Index string
+	Deps  []models.Dep
+	Wasm  *models.Wasm
+	Err   error
+}
+
+// Stop stops the running program, cancels a running compile and closes the connection to the
+// server, abandoning any request, share or deploy in progress.
+type Stop struct{}
+
+// CompileFailed is dispatched when a source package fails to parse or type-check
+type CompileFailed struct {
+	Path   string
+	Errors []error
+}
+type ClearDiagnostics struct{}
+
+// CheckDue is dispatched when the source hasn't changed for a moment, so package Path can be
+// type-checked
+type CheckDue struct{ Path string }
+
+// CheckComplete is dispatched when a source package has been type-checked in the background
+type CheckComplete struct {
+	Path   string
+	Errors []error
+}
+
+// TestStart compiles the tests for a package and runs them in the iframe
+type TestStart struct {
+	Path string // Path of the package to test
+}
+
 type DragEnter struct{}
 type DragLeave struct{}
 type DragDrop struct {
@@ -61,31 +185,57 @@ type DragDrop struct {
 	Changed map[string]map[string]bool
 }
 
-type Send struct{ Message services.Message }
+// Send sends a message for the operation ID
+type Send struct {
+	ID      int
+	Message services.Message
+}
+
+// Dial starts an operation on the connection to the server, which is opened if it's not already.
+// Operations share the connection: each is given an ID when it's opened, which is sent with its
+// messages so the responses are routed back to the actions from Dial.
 type Dial struct {
-	Url     string
-	Open    func() flux.ActionInterface
+	Open    func(id int) flux.ActionInterface
 	Message func(interface{}) flux.ActionInterface
 	Close   func() flux.ActionInterface
+
+	Stoppable bool // the operation is cancelled by Stop
 }
 
+// ConnectionOpened, ConnectionClosed and ConnectionLost are dispatched by the connection.
+// ConnectionMessage is dispatched with each response to operation ID, and ConnectionDone when the
+// server has finished it.
+type ConnectionOpened struct{}
+type ConnectionClosed struct{}
+type ConnectionLost struct{ Err error }
+type ConnectionMessage struct {
+	ID      int
+	Message services.Message
+}
+type ConnectionDone struct{ ID int }
+
+// Redial opens a new connection after the connection was lost
+type Redial struct{}
+
 type ShareStart struct{}
-type ShareOpen struct{}
+type ShareOpen struct{ ID int }
 type ShareMessage struct{ Message interface{} }
 type ShareClose struct{}
 
 type DeployStart struct{}
-type DeployOpen struct{}
+type DeployOpen struct{ ID int }
 type DeployMessage struct{ Message interface{} }
 type DeployClose struct{}
 
 type RequestStart struct {
 	Type models.RequestType
-	Path string // Path to get (for GetRequest and InitialiseRequest)
+	Path string // Path to get (for GetRequest and InitialiseRequest), or package to test (for UpdateRequest with Test)
 	Run  bool   // Run after update? (for UpdateRequest)
+	Test bool   // Run tests for Path after update? (for UpdateRequest)
 }
 type RequestOpen struct {
 	*RequestStart
+	ID int
 }
 type RequestMessage struct {
 	*RequestStart
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/dave/jsgo/config"
+	"github.com/dave/jsgo/server/play/messages"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores/builderjs"
+	"github.com/dave/services"
+	"github.com/dave/services/builder/buildermsg"
+	"github.com/dave/services/constor/constormsg"
+	"github.com/dave/services/deployer/deployermsg"
+	gbuild "github.com/gopherjs/gopherjs/build"
+	"github.com/gopherjs/gopherjs/compiler"
+	"github.com/gopherjs/gopherjs/compiler/prelude"
+	"golang.org/x/mod/module"
+)
+
+func init() {
+	// GopherJS compiles the standard library for the GOOS of build.Default (and the architecture of
+	// the server), and the virtual file system in the editor handles linux system calls
+	build.Default.GOOS = "linux"
+}
+
+// builder compiles packages and all their dependencies. Standard library packages are compiled by
+// GopherJS (which adds the natives), source packages sent by the editor are compiled from the
+// source, and all others are compiled from the files in GOPATH or the module cache (at the version
+// required by the go.mod file in the source, if any).
+type builder struct {
+	session  *gbuild.Session
+	source   map[string]map[string]string
+	required map[string]string
+	tags     []string
+	minify   bool
+	send     func(services.Message)
+
+	archives map[string]*compiler.Archive
+	packages map[string]*types.Package
+	// deps are the compiled archives in the order they should be loaded
+	deps []*compiler.Archive
+}
+
+func newBuilder(source map[string]map[string]string, tags []string, minify bool, send func(services.Message)) (*builder, error) {
+	required, err := requirements(source)
+	if err != nil {
+		return nil, err
+	}
+	return &builder{
+		session: gbuild.NewSession(&gbuild.Options{
+			Quiet:     true,
+			Minify:    minify,
+			BuildTags: tags,
+		}),
+		source:   source,
+		required: required,
+		tags:     tags,
+		minify:   minify,
+		send:     send,
+		archives: map[string]*compiler.Archive{},
+		packages: map[string]*types.Package{},
+	}, nil
+}
+
+func (b *builder) build(path string) error {
+	if path == "unsafe" || b.archives[path] != nil {
+		return nil
+	}
+	var archive *compiler.Archive
+	if b.source[path] == nil && isStandard(path) {
+		b.send(buildermsg.Building{Message: path})
+		a, err := b.session.BuildImportPath(path)
+		if err != nil {
+			return err
+		}
+		for _, imp := range a.Imports {
+			if err := b.build(imp); err != nil {
+				return err
+			}
+		}
+		archive = a
+	} else {
+		files, ok := b.source[path]
+		if !ok {
+			b.send(buildermsg.Building{Message: path})
+			dir, err := packageDir(path, requiredVersion(b.required, path))
+			if err != nil {
+				return err
+			}
+			if files, err = readFiles(dir, []string{".go", ".inc.js"}); err != nil {
+				return err
+			}
+		}
+		imports, err := builderjs.Imports(files, b.tags, false)
+		if err != nil {
+			return err
+		}
+		for _, imp := range imports {
+			if err := b.build(imp); err != nil {
+				return err
+			}
+		}
+		source := map[string]map[string]string{path: files}
+		a, err := builderjs.BuildPackage(path, source, b.tags, b.deps, b.minify, false, b.archives, b.packages)
+		if err != nil {
+			return err
+		}
+		archive = a
+	}
+	b.archives[path] = archive
+	b.deps = append(b.deps, archive)
+	return nil
+}
+
+// update sends the archives of all the dependencies of the source packages. Archives with the same
+// hash as in the editor's cache are marked as unchanged in the index and not sent.
+func (s *Server) update(source map[string]map[string]string, cache map[string]string, minify bool, tags []string, send func(services.Message)) error {
+	b, err := newBuilder(source, tags, minify, send)
+	if err != nil {
+		return err
+	}
+	if err := b.build("runtime"); err != nil {
+		return err
+	}
+	for _, files := range source {
+		// include the imports of the tests so they can be run without another update
+		imports, err := builderjs.Imports(files, tags, true)
+		if err != nil {
+			return err
+		}
+		for _, imp := range imports {
+			if source[imp] != nil {
+				continue
+			}
+			if err := b.build(imp); err != nil {
+				return err
+			}
+		}
+	}
+	index := deployermsg.ArchiveIndex{}
+	archive := func(path string, a *compiler.Archive, js, hash []byte) error {
+		item := deployermsg.ArchiveIndexItem{
+			Hash:      fmt.Sprintf("%x", hash),
+			Unchanged: cache[path] == fmt.Sprintf("%x", hash),
+		}
+		index[path] = item
+		if item.Unchanged {
+			return nil
+		}
+		if err := s.storePackage(path, item.Hash, a, js); err != nil {
+			return err
+		}
+		send(deployermsg.Archive{Path: path, Hash: item.Hash, Standard: path == "prelude" || isStandard(path)})
+		return nil
+	}
+	js, hash := preludeJs(minify)
+	if err := archive("prelude", nil, js, hash); err != nil {
+		return err
+	}
+	for _, a := range b.deps {
+		if source[a.ImportPath] != nil {
+			continue
+		}
+		js, hash, err := builderjs.GetPackageCode(context.Background(), a, minify, true)
+		if err != nil {
+			return err
+		}
+		if err := archive(a.ImportPath, a, js, hash); err != nil {
+			return err
+		}
+	}
+	send(index)
+	return nil
+}
+
+// deploy compiles the main package and stores the JS of all the packages, a loader that runs them
+// and an index page.
+func (s *Server) deploy(main string, source map[string]map[string]string, tags []string, send func(services.Message)) error {
+	b, err := newBuilder(source, tags, true, send)
+	if err != nil {
+		return err
+	}
+	if err := b.build("runtime"); err != nil {
+		return err
+	}
+	if err := b.build(main); err != nil {
+		return err
+	}
+	send(buildermsg.Building{Done: true})
+
+	var files, paths []string
+	store := func(path string, a *compiler.Archive, js, hash []byte) error {
+		h := fmt.Sprintf("%x", hash)
+		if err := s.storePackage(path, h, a, js); err != nil {
+			return err
+		}
+		files = append(files, fmt.Sprintf("%s.%s.js", path, h))
+		paths = append(paths, path)
+		send(constormsg.Storing{Finished: len(files)})
+		return nil
+	}
+	js, hash := preludeJs(true)
+	if err := store("prelude", nil, js, hash); err != nil {
+		return err
+	}
+	for _, a := range b.deps {
+		js, hash, err := builderjs.GetPackageCode(context.Background(), a, true, true)
+		if err != nil {
+			return err
+		}
+		if err := store(a.ImportPath, a, js, hash); err != nil {
+			return err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := loaderTemplate.Execute(buf, struct {
+		Main         string
+		Files, Paths []string
+	}{main, files, paths}); err != nil {
+		return err
+	}
+	loader, loaderHash := buf.Bytes(), sum(buf.Bytes())
+	if err := s.store(config.Pkg, fmt.Sprintf("%s.%s.js", main, loaderHash), loader); err != nil {
+		return err
+	}
+
+	page := defaultIndex
+	if contents, ok := source[main]["index.jsgo.html"]; ok {
+		page = contents
+	}
+	t, err := template.New("index").Parse(page)
+	if err != nil {
+		return err
+	}
+	buf = &bytes.Buffer{}
+	if err := t.Execute(buf, struct{ Script string }{fmt.Sprintf("../_%s/%s.%s.js", config.Pkg, main, loaderHash)}); err != nil {
+		return err
+	}
+	indexHash := sum(buf.Bytes())
+	if err := s.store(config.Index, indexHash, buf.Bytes()); err != nil {
+		return err
+	}
+	send(constormsg.Storing{Done: true})
+
+	send(messages.DeployComplete{Main: loaderHash, Index: indexHash})
+	return nil
+}
+
+// share stores the source and sends the hash that the editor loads it from.
+func (s *Server) share(source map[string]map[string]string, tags []string, send func(services.Message)) error {
+	source, run := models.UnpackRunConfigs(source)
+	b, err := json.Marshal(models.SharePack{
+		Version: 0,
+		Source:  source,
+		Tags:    tags,
+		Run:     run,
+	})
+	if err != nil {
+		return err
+	}
+	hash := sum(b)
+	send(constormsg.Storing{})
+	if err := s.store(config.Src, hash+".json", b); err != nil {
+		return err
+	}
+	send(constormsg.Storing{Finished: 1, Done: true})
+	send(messages.ShareComplete{Hash: hash})
+	return nil
+}
+
+// storePackage stores the JS of a package and, unless it is the prelude, the archive (stripped of
+// JS) that the editor compiles against.
+func (s *Server) storePackage(path, hash string, archive *compiler.Archive, js []byte) error {
+	// the path is used in the file name
+	if err := module.CheckImportPath(path); err != nil {
+		return err
+	}
+	if err := s.store(config.Pkg, fmt.Sprintf("%s.%s.js", path, hash), js); err != nil {
+		return err
+	}
+	if archive == nil {
+		return nil
+	}
+	stripped := *archive
+	stripped.Declarations = nil
+	stripped.IncJSCode = nil
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(&stripped); err != nil {
+		return err
+	}
+	return s.store(config.Pkg, fmt.Sprintf("%s.%s.ax", path, hash), buf.Bytes())
+}
+
+// store writes a file that will be served from host. Files are named by the hash of the contents,
+// so existing files are not written again.
+func (s *Server) store(host, name string, contents []byte) error {
+	fpath := filepath.Join(s.dir, host, filepath.FromSlash(name))
+	if _, err := os.Stat(fpath); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fpath, contents, 0666)
+}
+
+// preludeJs returns the GopherJS prelude. It runs in the global scope, and registers an empty
+// loader function so it can be loaded like any other package.
+func preludeJs(minify bool) (js []byte, hash []byte) {
+	p := prelude.Prelude
+	if minify {
+		p = prelude.Minified
+	}
+	js = []byte(p + "\n$load[\"prelude\"] = function () {};")
+	h := sha1.Sum(js)
+	return js, h[:]
+}
+
+func sum(b []byte) string {
+	return fmt.Sprintf("%x", sha1.Sum(b))
+}
+
+var loaderTemplate = template.Must(template.New("loader").Parse(`"use strict";
+var $load = {};
+(function () {
+	var src = document.currentScript.src;
+	var base = src.substring(0, src.lastIndexOf("/" + {{ printf "%q" .Main }} + ".") + 1);
+	var files = [{{ range $i, $f := .Files }}{{ if $i }}, {{ end }}{{ printf "%q" $f }}{{ end }}];
+	var paths = [{{ range $i, $p := .Paths }}{{ if $i }}, {{ end }}{{ printf "%q" $p }}{{ end }}];
+	var count = 0;
+	var done = function () {
+		count++;
+		if (window.jsgoProgress) {
+			window.jsgoProgress(count, files.length);
+		}
+		if (count < files.length) {
+			return;
+		}
+		for (var i = 0; i < paths.length; i++) {
+			$load[paths[i]]();
+		}
+		var $mainPkg = $packages[{{ printf "%q" .Main }}];
+		$synthesizeMethods();
+		$packages["runtime"].$init();
+		$go($mainPkg.$init, []);
+		$flushConsole();
+	};
+	for (var i = 0; i < files.length; i++) {
+		var script = document.createElement("script");
+		script.src = base + files[i];
+		script.async = false;
+		script.onload = done;
+		document.head.appendChild(script);
+	}
+})();
+`))
+
+const defaultIndex = `<html>
+	<head>
+		<meta charset="utf-8">
+	</head>
+	<body>
+		<script src="{{ .Script }}"></script>
+	</body>
+</html>
+`
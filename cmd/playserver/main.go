@@ -0,0 +1,46 @@
+// Command playserver is a compile server for play that runs on the local machine. It implements the
+// websocket protocol used by the play editor, loading package source from GOPATH or the module
+// cache, compiling archives with GopherJS and serving the files that the editor downloads.
+//
+// Start the server:
+//
+//	playserver -addr localhost:8080 -origin https://play.jsgo.io
+//
+// ... then select it in the editor with the "Server..." option, or open the editor with the
+// server query parameter (e.g. /?server=http://localhost:8080). Only the editors at the -origin
+// URLs (and the editor served with -static) can use the server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	var (
+		addr   = flag.String("addr", "localhost:8080", "address to listen on")
+		dir    = flag.String("dir", filepath.Join(os.TempDir(), "playserver"), "directory to store compiled archives and shared projects")
+		static = flag.String("static", "", "directory to serve the editor from (optional, e.g. the output of gopherjs build)")
+		origin = flag.String("origin", "", "comma separated origins of editors that may use the server (e.g. https://play.jsgo.io)")
+	)
+	flag.Parse()
+
+	s, err := New(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *origin != "" {
+		s.Origins = strings.Split(*origin, ",")
+	}
+	if *static != "" {
+		s.Static = http.FileServer(http.Dir(*static))
+	}
+
+	fmt.Printf("serving on http://%s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, s))
+}
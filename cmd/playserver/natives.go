@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/gopherjs/gopherjs/compiler/natives"
+)
+
+func init() {
+	natives.FS = overlay{FileSystem: natives.FS, files: map[string]string{
+		"/src/syscall/play_stdin.go": stdinNative,
+	}}
+}
+
+// stdinNative replaces syscall.Read when the standard library is compiled. GopherJS system calls
+// are synchronous, so a read from stdin can't wait for the user to type in the console. This passes
+// the read to goReadWait in the editor (see stores/frame.go) and blocks the goroutine until it calls
+// back, which makes the callers (os.File.Read etc.) blocking functions.
+const stdinNative = `// +build js
+
+package syscall
+
+import "github.com/gopherjs/gopherjs/js"
+
+func Read(fd int, p []byte) (n int, err error) {
+	wait := js.Global.Get("goReadWait")
+	if fd != 0 || len(p) == 0 || wait == js.Undefined {
+		return read(fd, p)
+	}
+	s := js.InternalObject(p)
+	offset := s.Get("$offset").Int()
+	c := make(chan int, 1)
+	wait.Invoke(s.Get("$array").Call("subarray", offset, offset+len(p)), func(n int) { c <- n })
+	return <-c, nil
+}
+`
+
+// overlay adds files to a file system
+type overlay struct {
+	http.FileSystem
+	files map[string]string // name -> contents
+}
+
+func (o overlay) Open(name string) (http.File, error) {
+	if contents, ok := o.files[name]; ok {
+		return &overlayFile{Reader: bytes.NewReader([]byte(contents)), info: overlayInfo{name: path.Base(name), size: int64(len(contents))}}, nil
+	}
+	f, err := o.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	var added []os.FileInfo
+	for n, contents := range o.files {
+		if path.Dir(n) == name {
+			added = append(added, overlayInfo{name: path.Base(n), size: int64(len(contents))})
+		}
+	}
+	if len(added) == 0 {
+		return f, nil
+	}
+	return overlayDir{File: f, added: added}, nil
+}
+
+// overlayDir is a directory with the added files listed after its own
+type overlayDir struct {
+	http.File
+	added []os.FileInfo
+}
+
+func (d overlayDir) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := d.File.Readdir(count)
+	if err != nil || count > 0 {
+		// only complete listings (as read by the GopherJS build) include the added files
+		return infos, err
+	}
+	return append(infos, d.added...), nil
+}
+
+type overlayFile struct {
+	*bytes.Reader
+	info overlayInfo
+}
+
+func (f *overlayFile) Close() error                             { return nil }
+func (f *overlayFile) Readdir(count int) ([]os.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *overlayFile) Stat() (os.FileInfo, error)               { return f.info, nil }
+
+type overlayInfo struct {
+	name string
+	size int64
+}
+
+func (i overlayInfo) Name() string       { return i.name }
+func (i overlayInfo) Size() int64        { return i.size }
+func (i overlayInfo) Mode() os.FileMode  { return 0444 }
+func (i overlayInfo) ModTime() time.Time { return time.Time{} }
+func (i overlayInfo) IsDir() bool        { return false }
+func (i overlayInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dave/jsgo/config"
+	"github.com/dave/jsgo/server/play/messages"
+	"github.com/dave/jsgo/server/servermsg"
+	"github.com/dave/play/models"
+	"github.com/dave/services"
+	"github.com/gorilla/websocket"
+	"golang.org/x/mod/module"
+)
+
+// Server implements the play protocol. The websocket handler is at /_play/, the files the editor
+// downloads are served from /_pkg/, /_src/ and /_index/, and the WebAssembly compiler is at /_wasm/
+// - the layout expected by backend.New.
+type Server struct {
+	// Static serves any requests not handled by the compile server (e.g. the editor itself)
+	Static http.Handler
+
+	// Origins are the origins (e.g. https://play.jsgo.io) of editors that may use the server, in
+	// addition to the server itself. Requests from other web pages are refused, so they can't read
+	// the source of private packages.
+	Origins []string
+
+	dir string
+	mux *http.ServeMux
+
+	// GopherJS build sessions can't be used concurrently, so requests are compiled one at a time
+	mutex sync.Mutex
+	queue int32
+}
+
+// New returns a server that stores files in dir.
+func New(dir string) (*Server, error) {
+	s := &Server{
+		dir: dir,
+		mux: http.NewServeMux(),
+	}
+	for _, host := range []string{config.Pkg, config.Src, config.Index} {
+		if err := os.MkdirAll(filepath.Join(dir, host), 0777); err != nil {
+			return nil, err
+		}
+		s.mux.Handle("/_"+host+"/", http.StripPrefix("/_"+host+"/", s.files(host)))
+	}
+	s.mux.HandleFunc("/_play/", s.play)
+	s.mux.Handle("/_wasm/", http.StripPrefix("/_wasm/", http.HandlerFunc(s.wasm)))
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if s.Static == nil {
+			http.NotFound(w, r)
+			return
+		}
+		s.Static.ServeHTTP(w, r)
+	})
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.allowed(r) {
+		http.Error(w, "origin not allowed (see the -origin flag)", http.StatusForbidden)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		// the editor may be served from a different origin
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// allowed is true if the request is from the server itself or one of Origins. Requests without an
+// Origin header aren't from scripts in another web page (e.g. script tags), so they are allowed.
+func (s *Server) allowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	for _, o := range s.Origins {
+		if origin == strings.TrimSuffix(o, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) files(host string) http.Handler {
+	fs := http.FileServer(http.Dir(filepath.Join(s.dir, host)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if host == config.Index {
+			// index pages are stored without an extension
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		fs.ServeHTTP(w, r)
+	})
+}
+
+// play handles a connection from the editor. Each message is wrapped in a models.Envelope with the
+// ID of its operation. Operations run concurrently: the responses are sent with the same ID, followed
+// by an envelope with Done when the operation has finished. If the first message isn't wrapped, the
+// editor only supports the original protocol: that operation is run, then the connection is closed.
+func (s *Server) play(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     s.allowed,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	// the operations write concurrently, but a websocket only supports one writer
+	var mutex sync.Mutex
+	write := func(b []byte) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err := conn.WriteMessage(websocket.TextMessage, b); err != nil {
+			log.Println(err)
+		}
+	}
+
+	var wait sync.WaitGroup
+	defer wait.Wait()
+
+	for first := true; ; first = false {
+		_, b, err := conn.ReadMessage()
+		if err != nil {
+			// the editor has closed the connection
+			return
+		}
+		var e models.Envelope
+		if err := json.Unmarshal(b, &e); err != nil || e.ID == 0 {
+			if !first {
+				log.Println("message without an operation ID")
+				continue
+			}
+			s.operation(b, func(message services.Message) {
+				b, _, err := messages.Marshal(message)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+				write(b)
+			})
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+		wait.Add(1)
+		go func(id int, message []byte) {
+			defer wait.Done()
+			send := func(message services.Message) {
+				b, _, err := messages.Marshal(message)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+				if b, err = json.Marshal(models.Envelope{ID: id, Message: b}); err != nil {
+					log.Println(err)
+					return
+				}
+				write(b)
+			}
+			s.operation(message, send)
+			b, err := json.Marshal(models.Envelope{ID: id, Done: true})
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			write(b)
+		}(e.ID, e.Message)
+	}
+}
+
+// operation runs the operation for the encoded message. Errors are sent to the editor.
+func (s *Server) operation(b []byte, send func(services.Message)) {
+	m, err := messages.Unmarshal(b)
+	if err != nil {
+		send(servermsg.Error{Message: err.Error()})
+		return
+	}
+	if err := s.handle(m, send); err != nil {
+		log.Println(err)
+		send(servermsg.Error{Message: err.Error()})
+	}
+}
+
+func (s *Server) handle(m services.Message, send func(services.Message)) error {
+	if err := check(m); err != nil {
+		return err
+	}
+	switch m := m.(type) {
+	case messages.Get:
+		source, err := s.get(m.Path, send)
+		if err != nil {
+			return err
+		}
+		send(messages.GetComplete{Source: source})
+		return nil
+	case messages.Initialise:
+		source, err := s.get(m.Path, send)
+		if err != nil {
+			return err
+		}
+		send(messages.GetComplete{Source: source})
+		return s.wait(send, func() error { return s.update(source, nil, m.Minify, nil, send) })
+	case messages.Update:
+		return s.wait(send, func() error { return s.update(m.Source, m.Cache, m.Minify, m.Tags, send) })
+	case messages.Share:
+		return s.share(m.Source, m.Tags, send)
+	case messages.Deploy:
+		return s.wait(send, func() error { return s.deploy(m.Main, m.Source, m.Tags, send) })
+	}
+	return fmt.Errorf("unsupported message %T", m)
+}
+
+// check returns an error if the paths in m aren't valid
+func check(m services.Message) error {
+	switch m := m.(type) {
+	case messages.Update:
+		return checkSource(m.Source)
+	case messages.Share:
+		return checkSource(m.Source)
+	case messages.Deploy:
+		if err := module.CheckImportPath(m.Main); err != nil {
+			return err
+		}
+		return checkSource(m.Source)
+	}
+	// the paths of Get and Initialise may have a version, so they are checked by get
+	return nil
+}
+
+// wait runs f when no other requests are compiling, reporting the position in the queue.
+func (s *Server) wait(send func(services.Message), f func() error) error {
+	position := atomic.AddInt32(&s.queue, 1)
+	send(servermsg.Queueing{Position: int(position)})
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	atomic.AddInt32(&s.queue, -1)
+	send(servermsg.Queueing{Done: true})
+	return f()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dave/jsgo/config"
+	"github.com/dave/services"
+	"github.com/dave/services/getter/gettermsg"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// get returns the source of package path, which may have a version suffix (e.g. path@v1.2.3). The
+// source is keyed by the path without the version.
+func (s *Server) get(path string, send func(services.Message)) (map[string]map[string]string, error) {
+	send(gettermsg.Downloading{Starting: true, Message: path})
+	var version string
+	if i := strings.Index(path, "@"); i > -1 {
+		path, version = path[:i], path[i+1:]
+	}
+	if err := module.CheckImportPath(path); err != nil {
+		return nil, err
+	}
+	dir, err := packageDir(path, version)
+	if err != nil {
+		return nil, err
+	}
+	files, err := readFiles(dir, config.ValidExtensions)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no source files in %s", path)
+	}
+	send(gettermsg.Downloading{Done: true})
+	return map[string]map[string]string{path: files}, nil
+}
+
+// readFiles reads the files in dir with one of the extensions.
+func readFiles(dir string, extensions []string) (map[string]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	for _, info := range infos {
+		if info.IsDir() || !hasExtension(info.Name(), extensions) {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[info.Name()] = string(b)
+	}
+	return files, nil
+}
+
+func hasExtension(name string, extensions []string) bool {
+	for _, ext := range extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+var dirs = struct {
+	sync.Mutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// packageDir returns the directory of package path. If version is empty, GOROOT and GOPATH are
+// searched first, then the latest version in the module cache. Otherwise the module that provides
+// path is used at version. Modules are downloaded to the module cache if needed.
+func packageDir(path, version string) (string, error) {
+	key := path + "@" + version
+	dirs.Lock()
+	defer dirs.Unlock()
+	if dir, ok := dirs.m[key]; ok {
+		return dir, nil
+	}
+	dir, err := findDir(path, version)
+	if err != nil {
+		return "", err
+	}
+	dirs.m[key] = dir
+	return dir, nil
+}
+
+func findDir(path, version string) (string, error) {
+	if version == "" {
+		if p, err := build.Import(path, "", build.FindOnly); err == nil {
+			return p.Dir, nil
+		}
+		version = "latest"
+	}
+	// The module path is a prefix of the package path, so try the longest first
+	for mod := path; mod != "." && mod != "/"; mod = filepath.ToSlash(filepath.Dir(mod)) {
+		out, err := exec.Command("go", "mod", "download", "-json", mod+"@"+version).Output()
+		if err != nil {
+			continue
+		}
+		var m struct {
+			Dir   string
+			Error string
+		}
+		if err := json.Unmarshal(out, &m); err != nil || m.Error != "" || m.Dir == "" {
+			continue
+		}
+		return filepath.Join(m.Dir, filepath.FromSlash(strings.TrimPrefix(path, mod))), nil
+	}
+	if version == "latest" {
+		return "", fmt.Errorf("%s not found in GOROOT, GOPATH or the module cache", path)
+	}
+	return "", fmt.Errorf("%s@%s not found", path, version)
+}
+
+// requirements returns the versions of the modules required by the go.mod file in source.
+func requirements(source map[string]map[string]string) (map[string]string, error) {
+	for _, files := range source {
+		contents, ok := files["go.mod"]
+		if !ok {
+			continue
+		}
+		f, err := modfile.ParseLax("go.mod", []byte(contents), nil)
+		if err != nil {
+			return nil, err
+		}
+		required := map[string]string{}
+		for _, r := range f.Require {
+			required[r.Mod.Path] = r.Mod.Version
+		}
+		return required, nil
+	}
+	return nil, nil
+}
+
+// requiredVersion returns the version of the required module that provides package path.
+func requiredVersion(required map[string]string, path string) string {
+	var module, version string
+	for mod, v := range required {
+		if (path == mod || strings.HasPrefix(path, mod+"/")) && len(mod) > len(module) {
+			module, version = mod, v
+		}
+	}
+	return version
+}
+
+// isStandard is true if path is in GOROOT.
+func isStandard(path string) bool {
+	p, err := build.Import(path, "", build.FindOnly)
+	return err == nil && p.Goroot
+}
+
+// checkSource returns an error if a package path or file name in source isn't valid. They are used
+// in file names, so they must not be able to refer outside the directory they are written to.
+func checkSource(source map[string]map[string]string) error {
+	for path, files := range source {
+		if err := module.CheckImportPath(path); err != nil {
+			return err
+		}
+		for name := range files {
+			if err := checkFileName(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkFileName returns an error if name isn't a plain file name
+func checkFileName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	return nil
+}
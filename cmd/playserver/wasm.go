@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dave/play/models"
+	"golang.org/x/mod/module"
+)
+
+// wasm handles the WebAssembly compiler. A POST to build with a models.WasmRequest replies with the
+// binary, or the output of go build if it fails, and wasm_exec.js serves the support script from
+// GOROOT that runs the binary.
+func (s *Server) wasm(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "build":
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// A form in another web page can only post text/plain, so this (and the origin check in
+		// ServeHTTP) stops other pages running go build.
+		if t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || t != "application/json" {
+			http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		var request models.WasmRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, err := buildWasm(request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/wasm")
+		w.Write(b)
+	case "wasm_exec.js":
+		goroot, err := exec.Command("go", "env", "GOROOT").Output()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join(strings.TrimSpace(string(goroot)), "misc", "wasm", "wasm_exec.js"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// buildWasm compiles the main package with GOOS=js GOARCH=wasm. The source packages are written to
+// a temporary GOPATH, which is searched before the local GOPATH. If the main package is in a module
+// (a package in the source with a go.mod file), it's built in module mode from the module directory.
+func buildWasm(request models.WasmRequest) ([]byte, error) {
+	if request.Source[request.Path] == nil {
+		return nil, fmt.Errorf("%s not found", request.Path)
+	}
+	// the paths and file names are written to the temporary directory
+	if err := module.CheckImportPath(request.Path); err != nil {
+		return nil, err
+	}
+	if err := checkSource(request.Source); err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "playserver-wasm")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var module string
+	for path, files := range request.Source {
+		pdir := filepath.Join(dir, "src", filepath.FromSlash(path))
+		if err := os.MkdirAll(pdir, 0777); err != nil {
+			return nil, err
+		}
+		for name, contents := range files {
+			if err := ioutil.WriteFile(filepath.Join(pdir, name), []byte(contents), 0666); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := files["go.mod"]; ok && (request.Path == path || strings.HasPrefix(request.Path, path+"/")) && len(path) > len(module) {
+			module = path
+		}
+	}
+
+	out := filepath.Join(dir, "main.wasm")
+	cmd := exec.Command("go", "build", "-o", out, "-tags", strings.Join(request.Tags, " "))
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if module != "" {
+		cmd.Dir = filepath.Join(dir, "src", filepath.FromSlash(module))
+		cmd.Args = append(cmd.Args, "."+strings.TrimPrefix(request.Path, module))
+		cmd.Env = append(cmd.Env, "GO111MODULE=on")
+	} else {
+		cmd.Dir = dir
+		cmd.Args = append(cmd.Args, request.Path)
+		cmd.Env = append(cmd.Env, "GO111MODULE=off", "GOPATH="+dir+string(filepath.ListSeparator)+build.Default.GOPATH)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		// the paths in the output are relative to the temporary directory
+		return nil, fmt.Errorf("%s", strings.Replace(string(output), dir+string(filepath.Separator), "", -1))
+	}
+	return ioutil.ReadFile(out)
+}
@@ -3,15 +3,20 @@ package main
 import (
 	"github.com/dave/play/actions"
 	"github.com/dave/play/stores"
+	"github.com/dave/play/stores/worker"
 	"github.com/dave/play/views"
 	"github.com/gopherjs/vecty"
 	"github.com/vincent-petithory/dataurl"
 	"honnef.co/go/js/dom"
 )
 
-var document = dom.GetWindow().Document().(dom.HTMLDocument)
-
 func main() {
+	if worker.IsWorker() {
+		// the same script runs the compiler in a Web Worker
+		worker.Serve()
+		return
+	}
+	document := dom.GetWindow().Document().(dom.HTMLDocument)
 	if document.ReadyState() == "loading" {
 		document.AddEventListener("DOMContentLoaded", false, func(dom.Event) {
 			go run()
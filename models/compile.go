@@ -0,0 +1,23 @@
+package models
+
+// Dep is the JS of a compiled package. A compiled program is a list of deps in the order they
+// should be loaded.
+type Dep struct {
+	Path      string
+	Js        []byte
+	SourceMap []byte // SourceMap is the JSON source map of the JS, for packages compiled from source
+}
+
+// Wasm is a main package compiled to WebAssembly
+type Wasm struct {
+	Binary  []byte
+	Support []byte // wasm_exec.js from the Go distribution that compiled Binary
+}
+
+// WasmRequest is the body of a request to compile a main package to WebAssembly, sent to a
+// self-hosted server.
+type WasmRequest struct {
+	Path   string                       `json:"path"`
+	Source map[string]map[string]string `json:"source"`
+	Tags   []string                     `json:"tags"`
+}
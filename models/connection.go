@@ -0,0 +1,23 @@
+package models
+
+import "encoding/json"
+
+// ConnectionState is the state of the connection to the compile server
+type ConnectionState string
+
+const (
+	ConnectionIdle       ConnectionState = ""           // there's no connection
+	ConnectionConnecting ConnectionState = "connecting" // dialing
+	ConnectionOpen       ConnectionState = "open"
+	ConnectionRetrying   ConnectionState = "retrying" // the connection was lost and will be dialed again
+	ConnectionFailed     ConnectionState = "failed"   // the connection was lost and the retries failed
+)
+
+// Envelope wraps each message on the websocket with the ID of its operation (IDs start at 1). When
+// the server has finished an operation it sends an envelope with Done and no message. Message is
+// encoded with messages.Marshal.
+type Envelope struct {
+	ID      int             `json:"id"`
+	Message json.RawMessage `json:"message,omitempty"`
+	Done    bool            `json:"done,omitempty"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// Diagnostic is a compile error at a position in a source file
+type Diagnostic struct {
+	Path    string // Package path
+	File    string // Filename
+	Line    int    // Line number, starting at 1 (0 if unknown)
+	Column  int    // Column number, starting at 1 (0 if unknown)
+	Message string
+}
+
+// Position is a location in a file that the editor should move the cursor to
+type Position struct {
+	Line, Column int
+}
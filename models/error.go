@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Error is an error reported by the app. Repeated errors are combined, so Count is the number of
+// times it was reported and Time is the last time.
+type Error struct {
+	ID        int
+	Source    ErrorSource
+	Severity  Severity
+	Message   string
+	Time      time.Time
+	Count     int
+	Dismissed bool // Dismissed is true when the notification has been closed
+}
+
+// ErrorSource is the part of the app an error comes from
+type ErrorSource string
+
+const (
+	NetworkSource ErrorSource = "network" // the compile server and the package hosts
+	CompileSource ErrorSource = "compile" // compiling the project (but not errors in the source)
+	StorageSource ErrorSource = "storage" // local storage and IndexedDB
+	RuntimeSource ErrorSource = "runtime" // running the program
+	ProjectSource ErrorSource = "project" // changes to the project, e.g. adding a file that exists
+)
+
+type Severity string
+
+const (
+	ErrorSeverity   Severity = "error"
+	WarningSeverity Severity = "warning"
+)
@@ -12,6 +12,19 @@ const (
 	RemovePackageModal Modal = "remove-package-modal"
 	BuildTagsModal     Modal = "build-tags-modal"
 	HelpModal          Modal = "help-modal"
+	ServerModal        Modal = "server-modal"
+	ModuleModal        Modal = "module-modal"
+	FilesModal         Modal = "files-modal"
+	ErrorsModal        Modal = "errors-modal"
+
+	CreateWorkspaceModal    Modal = "create-workspace-modal"
+	RenameWorkspaceModal    Modal = "rename-workspace-modal"
+	DuplicateWorkspaceModal Modal = "duplicate-workspace-modal"
+	DeleteWorkspaceModal    Modal = "delete-workspace-modal"
+	WorkspaceConflictModal  Modal = "workspace-conflict-modal"
+
+	CreateRunConfigModal Modal = "create-run-config-modal"
+	EditRunConfigModal   Modal = "edit-run-config-modal"
 )
 
 type RequestType string
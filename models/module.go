@@ -0,0 +1,15 @@
+package models
+
+// Module is the parsed go.mod file of the project.
+type Module struct {
+	Package string        // Package is the source package that contains the go.mod file
+	Path    string        // Path is the module path
+	Go      string        // Go is the version in the go directive
+	Require []Requirement // Require is ordered by path
+}
+
+type Requirement struct {
+	Path     string
+	Version  string
+	Indirect bool
+}
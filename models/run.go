@@ -0,0 +1,18 @@
+package models
+
+// RunConfig is a named configuration for running a main package.
+type RunConfig struct {
+	Name   string   `json:"name"`
+	Path   string   `json:"path"` // The main package
+	Tags   []string `json:"tags,omitempty"`
+	Args   []string `json:"args,omitempty"`
+	Env    []string `json:"env,omitempty"` // Environment variables in the form "key=value"
+	Minify bool     `json:"minify,omitempty"`
+	Target string   `json:"target,omitempty"` // GopherJSTarget or WasmTarget
+}
+
+// Targets of a run configuration
+const (
+	GopherJSTarget = ""     // Compiled to JS by GopherJS in the browser
+	WasmTarget     = "wasm" // Compiled to WebAssembly by the go command on a self-hosted server
+)
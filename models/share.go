@@ -1,9 +1,68 @@
 package models
 
+import (
+	"encoding/json"
+	"sort"
+)
+
 // SharePack is the structure of the data persisted on src.jsgo.io as json, so best to use json tags
 // to lower-case the names.
 type SharePack struct {
 	Version int                          `json:"version"`
-	Source  map[string]map[string]string `json:"source"` // Source packages for this build: map[<package>]map[<filename>]<contents>
-	Tags    []string                     `json:"tags"`   // Build tags
+	Source  map[string]map[string]string `json:"source"`        // Source packages for this build: map[<package>]map[<filename>]<contents>
+	Tags    []string                     `json:"tags"`          // Build tags
+	Run     []RunConfig                  `json:"run,omitempty"` // Run configurations
+}
+
+// RunConfigFile is the file that the run configurations of a main package are sent in when sharing,
+// because the share message only has the source and build tags. The name is reserved: files with it
+// can't be added, and projects that contain one can't be shared.
+const RunConfigFile = "play.run.json"
+
+// PackRunConfigs returns a copy of source with the run configurations added as RunConfigFile files
+// in their main packages.
+func PackRunConfigs(source map[string]map[string]string, configs []RunConfig) map[string]map[string]string {
+	byPath := map[string][]RunConfig{}
+	for _, config := range configs {
+		byPath[config.Path] = append(byPath[config.Path], config)
+	}
+	packed := map[string]map[string]string{}
+	for path, files := range source {
+		packed[path] = map[string]string{}
+		for name, contents := range files {
+			packed[path][name] = contents
+		}
+		if len(byPath[path]) == 0 {
+			continue
+		}
+		b, err := json.Marshal(byPath[path])
+		if err != nil {
+			continue
+		}
+		packed[path][RunConfigFile] = string(b)
+	}
+	return packed
+}
+
+// UnpackRunConfigs returns a copy of source without the RunConfigFile files, and the run
+// configurations they contain sorted by name. Files that can't be decoded are ignored.
+func UnpackRunConfigs(source map[string]map[string]string) (map[string]map[string]string, []RunConfig) {
+	unpacked := map[string]map[string]string{}
+	var configs []RunConfig
+	for path, files := range source {
+		unpacked[path] = map[string]string{}
+		for name, contents := range files {
+			if name != RunConfigFile {
+				unpacked[path][name] = contents
+				continue
+			}
+			var c []RunConfig
+			if err := json.Unmarshal([]byte(contents), &c); err != nil {
+				continue
+			}
+			configs = append(configs, c...)
+		}
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+	return unpacked, configs
 }
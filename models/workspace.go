@@ -0,0 +1,23 @@
+package models
+
+// Workspace is a project persisted in local storage.
+type Workspace struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// WorkspaceData is the state of a workspace. The source is stored separately in IndexedDB, so Source
+// is only persisted with the rest of the state in browsers without IndexedDB.
+type WorkspaceData struct {
+	Source         map[string]map[string]string `json:"source,omitempty"`
+	Tags           []string                     `json:"tags"`
+	Run            []RunConfig                  `json:"run,omitempty"`
+	RunConfig      string                       `json:"run-config,omitempty"` // Name of the selected run configuration
+	CurrentPackage string                       `json:"current-package"`
+	CurrentFile    string                       `json:"current-file"`
+
+	// Revision is incremented each time the workspace is saved, and Tab identifies the tab that
+	// saved it. These are used to detect that another tab has changed the workspace.
+	Revision int    `json:"revision"`
+	Tab      string `json:"tab,omitempty"`
+}
@@ -0,0 +1,129 @@
+package stores
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ansiStyle is the text style set by ANSI SGR escape sequences. Colours are 0-15, or -1 for the
+// default.
+type ansiStyle struct {
+	fg, bg                  int
+	bold, italic, underline bool
+}
+
+var defaultStyle = ansiStyle{fg: -1, bg: -1}
+
+// classes returns the CSS classes for the style (see views.Styles)
+func (a ansiStyle) classes() []string {
+	var c []string
+	if a.fg >= 0 {
+		c = append(c, fmt.Sprintf("ansi-fg-%d", a.fg))
+	}
+	if a.bg >= 0 {
+		c = append(c, fmt.Sprintf("ansi-bg-%d", a.bg))
+	}
+	if a.bold {
+		c = append(c, "ansi-bold")
+	}
+	if a.italic {
+		c = append(c, "ansi-italic")
+	}
+	if a.underline {
+		c = append(c, "ansi-underline")
+	}
+	return c
+}
+
+type ansiRun struct {
+	style ansiStyle
+	text  string
+}
+
+// parseANSI splits text into runs of the same style, starting with style. Other escape sequences
+// are removed. The style at the end of the text is returned, so it can continue in the next write.
+func parseANSI(text string, style ansiStyle) ([]ansiRun, ansiStyle) {
+	var runs []ansiRun
+	for {
+		i := strings.Index(text, "\x1b[")
+		if i == -1 {
+			break
+		}
+		if i > 0 {
+			runs = append(runs, ansiRun{style, text[:i]})
+		}
+		// CSI sequences end with a byte in the range 0x40-0x7e
+		end := strings.IndexFunc(text[i+2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e })
+		if end == -1 {
+			// incomplete sequence
+			text = ""
+			break
+		}
+		params, final := text[i+2:i+2+end], text[i+2+end]
+		text = text[i+3+end:]
+		if final == 'm' {
+			style = style.apply(params)
+		}
+	}
+	if text != "" {
+		runs = append(runs, ansiRun{style, text})
+	}
+	return runs, style
+}
+
+// apply applies the parameters of an SGR sequence
+func (a ansiStyle) apply(params string) ansiStyle {
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil {
+			// an empty parameter is a reset
+			code = 0
+		}
+		switch {
+		case code == 0:
+			a = defaultStyle
+		case code == 1:
+			a.bold = true
+		case code == 3:
+			a.italic = true
+		case code == 4:
+			a.underline = true
+		case code == 22:
+			a.bold = false
+		case code == 23:
+			a.italic = false
+		case code == 24:
+			a.underline = false
+		case code >= 30 && code <= 37:
+			a.fg = code - 30
+		case code == 39:
+			a.fg = -1
+		case code >= 40 && code <= 47:
+			a.bg = code - 40
+		case code == 49:
+			a.bg = -1
+		case code >= 90 && code <= 97:
+			a.fg = code - 90 + 8
+		case code >= 100 && code <= 107:
+			a.bg = code - 100 + 8
+		case code == 38 || code == 48:
+			// extended colours: only the first 16 of the 256 colour palette are supported, and
+			// 24 bit colours are skipped
+			if i+2 < len(codes) && codes[i+1] == "5" {
+				if n, err := strconv.Atoi(codes[i+2]); err == nil && n < 16 {
+					if code == 38 {
+						a.fg = n
+					} else {
+						a.bg = n
+					}
+				}
+				i += 2
+			} else if i+4 < len(codes) && codes[i+1] == "2" {
+				i += 4
+			}
+		}
+	}
+	return a
+}
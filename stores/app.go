@@ -2,16 +2,14 @@ package stores
 
 import (
 	"fmt"
-	"strconv"
-
-	"honnef.co/go/js/dom"
 
 	"strings"
 
 	"time"
 
 	"github.com/dave/flux"
-	"github.com/gopherjs/gopherjs/js"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
 )
 
 type App struct {
@@ -19,6 +17,13 @@ type App struct {
 	Watcher    flux.WatcherInterface
 	Notifier   flux.NotifierInterface
 
+	// The browser (see platform.go). These are set to the browser implementations by Init unless
+	// they have been set already.
+	Document Document
+	Storage  Storage
+	Location Location
+	Runner   Runner
+
 	Archive    *ArchiveStore
 	Editor     *EditorStore
 	Connection *ConnectionStore
@@ -31,10 +36,36 @@ type App struct {
 	Page       *PageStore
 	Source     *SourceStore
 	History    *HistoryStore
+	Diagnostic *DiagnosticStore
+	Check      *CheckStore
+	Backend    *BackendStore
+	Module     *ModuleStore
+	Undo       *UndoStore
+	Workspace  *WorkspaceStore
+	Console    *ConsoleStore
+	Filesystem *FilesystemStore
+	Error      *ErrorStore
+
+	// extra stores are registered after the others (the tests use this to read the stores between
+	// actions)
+	extra []flux.StoreInterface
 }
 
 func (a *App) Init() {
 
+	if a.Document == nil {
+		a.Document = browserDocument{}
+	}
+	if a.Storage == nil {
+		a.Storage = newBrowserStorage()
+	}
+	if a.Location == nil {
+		a.Location = browserLocation{}
+	}
+	if a.Runner == nil {
+		a.Runner = &frameRunner{app: a}
+	}
+
 	n := flux.NewNotifier()
 	a.Notifier = n
 	a.Watcher = n
@@ -51,11 +82,17 @@ func (a *App) Init() {
 	a.Page = NewPageStore(a)
 	a.Source = NewSourceStore(a)
 	a.History = NewHistoryStore(a)
-
-	a.Dispatcher = flux.NewDispatcher(
-		// Notifier:
-		a.Notifier,
-		// Stores:
+	a.Diagnostic = NewDiagnosticStore(a)
+	a.Check = NewCheckStore(a)
+	a.Backend = NewBackendStore(a)
+	a.Module = NewModuleStore(a)
+	a.Undo = NewUndoStore(a)
+	a.Workspace = NewWorkspaceStore(a)
+	a.Console = NewConsoleStore(a)
+	a.Filesystem = NewFilesystemStore(a)
+	a.Error = NewErrorStore(a)
+
+	stores := []flux.StoreInterface{
 		a.Archive,
 		a.Editor,
 		a.Connection,
@@ -68,7 +105,17 @@ func (a *App) Init() {
 		a.Page,
 		a.Source,
 		a.History,
-	)
+		a.Diagnostic,
+		a.Check,
+		a.Backend,
+		a.Module,
+		a.Undo,
+		a.Workspace,
+		a.Console,
+		a.Filesystem,
+		a.Error,
+	}
+	a.Dispatcher = flux.NewDispatcher(a.Notifier, append(stores, a.extra...)...)
 }
 
 func (a *App) Dispatch(action flux.ActionInterface) chan struct{} {
@@ -83,13 +130,24 @@ func (a *App) Delete(key interface{}) {
 	a.Watcher.Delete(key)
 }
 
-func (a *App) Fail(err error) {
-	// TODO: improve this
-	js.Global.Call("alert", err.Error())
+// Fail reports an error. It's shown as a notification and added to the error log. This doesn't
+// block, so it can be called from a store.
+func (a *App) Fail(source models.ErrorSource, err error) {
+	a.report(source, models.ErrorSeverity, err)
+}
+
+// Warn reports an error that doesn't stop the app working (e.g. the archive cache isn't available)
+func (a *App) Warn(source models.ErrorSource, err error) {
+	a.report(source, models.WarningSeverity, err)
+}
+
+func (a *App) report(source models.ErrorSource, severity models.Severity, err error) {
+	a.Debug(fmt.Sprintf("%s %s:", source, severity), err.Error())
+	a.Dispatch(&actions.ReportError{Source: source, Severity: severity, Message: err.Error()})
 }
 
 func (a *App) Debug(message ...interface{}) {
-	js.Global.Get("console").Call("log", message...)
+	a.Document.Debug(message...)
 }
 
 var lastLog *struct{}
@@ -110,18 +168,12 @@ func (a *App) LogHide(args ...interface{}) {
 }
 
 func (a *App) Log(args ...interface{}) {
-	m := dom.GetWindow().Document().GetElementByID("message")
 	var message string
 	if len(args) > 0 {
 		message = strings.TrimSuffix(fmt.Sprintln(args...), "\n")
 	}
-	if m.InnerHTML() != message {
-		if message != "" {
-			js.Global.Get("console").Call("log", "Status", strconv.Quote(message))
-		}
-		requestAnimationFrame()
-		m.SetInnerHTML(message)
-		requestAnimationFrame()
+	if a.Document.Status() != message {
+		a.Document.SetStatus(message)
 		lastLog = &struct{}{}
 	}
 }
@@ -133,9 +185,3 @@ func (a *App) Logf(format string, args ...interface{}) {
 func (a *App) LogHidef(format string, args ...interface{}) {
 	a.LogHide(fmt.Sprintf(format, args...))
 }
-
-func requestAnimationFrame() {
-	c := make(chan struct{})
-	js.Global.Call("requestAnimationFrame", func() { close(c) })
-	<-c
-}
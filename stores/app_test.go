@@ -0,0 +1,467 @@
+package stores
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dave/flux"
+	"github.com/dave/jsgo/server/play/messages"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores/backend"
+	"github.com/dave/play/stores/builderjs"
+	"github.com/dave/services"
+	"github.com/gopherjs/gopherjs/compiler"
+)
+
+const (
+	testFile   = "package main\n\nfunc main() {}\n"
+	testEdited = "package main\n\nfunc main() {\n\tprintln(\"edited\")\n}\n"
+)
+
+func TestCompileRequestsArchives(t *testing.T) {
+	updates := make(chan messages.Update, 1)
+	app := newTestApp(&backend.Fake{
+		Serve: func(message services.Message, send func(services.Message)) error {
+			if m, ok := message.(messages.Update); ok {
+				updates <- m
+			}
+			return nil
+		},
+	})
+
+	<-app.Dispatch(&actions.LoadSource{
+		Source:         map[string]map[string]string{"main": {"main.go": testFile}},
+		CurrentPackage: "main",
+		CurrentFile:    "main.go",
+	})
+	<-app.Dispatch(&actions.UserChangedText{Text: testEdited, Changed: true})
+
+	var got string
+	read(app, func() { got = app.Source.Files("main")["main.go"] })
+	if got != testEdited {
+		t.Fatalf("source not changed: %q", got)
+	}
+	if got := app.Location.(*fakeLocation).Replaced(); !reflect.DeepEqual(got, []string{"/"}) {
+		t.Fatalf("page path replaced with %v", got)
+	}
+	var workspaces []models.Workspace
+	if found, err := app.Storage.Find("workspaces", &workspaces); err != nil || !found {
+		t.Fatalf("workspaces not saved: %v", err)
+	}
+	if len(workspaces) != 1 || workspaces[0].Name != "Untitled" {
+		t.Fatalf("unexpected workspaces %v", workspaces)
+	}
+
+	// the archives haven't been downloaded, so they are requested before compiling
+	<-app.Dispatch(&actions.CompileStart{})
+
+	select {
+	case m := <-updates:
+		if got := m.Source["main"]["main.go"]; got != testEdited {
+			t.Fatalf("update sent with source %q", got)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("update not sent")
+	}
+}
+
+func TestCompileWasm(t *testing.T) {
+	requests := make(chan models.WasmRequest, 1)
+	wasm := &models.Wasm{Binary: []byte("binary"), Support: []byte("support")}
+	app := newTestApp(&backend.Fake{
+		Serve: func(message services.Message, send func(services.Message)) error {
+			return nil
+		},
+		CompileWasm: func(request models.WasmRequest) (*models.Wasm, error) {
+			requests <- request
+			return wasm, nil
+		},
+	})
+
+	<-app.Dispatch(&actions.LoadSource{
+		Source:         map[string]map[string]string{"main": {"main.go": testFile}},
+		CurrentPackage: "main",
+		CurrentFile:    "main.go",
+		Run: []models.RunConfig{{
+			Name:   "wasm",
+			Path:   "main",
+			Target: models.WasmTarget,
+			Args:   []string{"-v"},
+			Env:    []string{"NAME=value"},
+			Minify: true,
+		}},
+		RunConfig: "wasm",
+	})
+	<-app.Dispatch(&actions.UserChangedText{Text: testEdited, Changed: true})
+	<-app.Dispatch(&actions.CompileStart{})
+
+	select {
+	case r := <-requests:
+		if r.Path != "main" || r.Source["main"]["main.go"] != testEdited {
+			t.Fatalf("unexpected request %#v", r)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("not compiled")
+	}
+
+	select {
+	case p := <-app.Runner.(*fakeRunner).programs:
+		if p.Path != "main" || p.Wasm != wasm {
+			t.Fatalf("unexpected program %#v", p)
+		}
+		if !reflect.DeepEqual(p.Args, []string{"main", "-v"}) {
+			t.Fatalf("unexpected args %v", p.Args)
+		}
+		if !reflect.DeepEqual(p.Env, map[string]string{"PWD": "/main", "NAME": "value"}) {
+			t.Fatalf("unexpected env %v", p.Env)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("not run")
+	}
+}
+
+func TestWorkspaceConflict(t *testing.T) {
+	app := newTestApp(&backend.Fake{})
+
+	<-app.Dispatch(&actions.LoadSource{
+		Source:         map[string]map[string]string{"main": {"main.go": testFile, "other.go": "package main\n"}},
+		CurrentPackage: "main",
+		CurrentFile:    "main.go",
+	})
+	<-app.Dispatch(&actions.UserChangedText{Text: testEdited, Changed: true})
+
+	var id string
+	read(app, func() { id = app.Workspace.Current() })
+	revision := func() int {
+		var data models.WorkspaceData
+		if found, err := app.Storage.Find("workspace-"+id, &data); err != nil || !found {
+			t.Fatalf("workspace not saved: %v", err)
+		}
+		return data.Revision
+	}
+	saved := revision()
+
+	// changing file doesn't change the content, so other tabs don't see a conflict
+	<-app.Dispatch(&actions.UserChangedFile{Name: "other.go"})
+	if got := revision(); got != saved {
+		t.Fatalf("revision changed from %d to %d when changing file", saved, got)
+	}
+	<-app.Dispatch(&actions.UserChangedText{Text: testFile, Changed: true})
+	if got := revision(); got != saved+1 {
+		t.Fatalf("revision %d after editing, expected %d", got, saved+1)
+	}
+
+	var conflict bool
+	<-app.Dispatch(&actions.WorkspaceChanged{ID: id, Revision: saved + 1})
+	read(app, func() { conflict = app.Workspace.Conflict() })
+	if conflict {
+		t.Fatal("conflict when another tab saved the same revision")
+	}
+	<-app.Dispatch(&actions.WorkspaceChanged{ID: id, Revision: saved + 2})
+	read(app, func() { conflict = app.Workspace.Conflict() })
+	if !conflict {
+		t.Fatal("no conflict when another tab changed the content")
+	}
+}
+
+func TestWorkspaceFiles(t *testing.T) {
+	app := newTestApp(&backend.Fake{})
+	db, err := app.Storage.Database("play", 1, filesStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the database is opened by Load, which loads the rest of the app too
+	read(app, func() { app.Workspace.db = db })
+
+	<-app.Dispatch(&actions.LoadSource{
+		Source:         map[string]map[string]string{"main": {"main.go": testFile}},
+		CurrentPackage: "main",
+		CurrentFile:    "main.go",
+	})
+	<-app.Dispatch(&actions.UserChangedText{Text: testEdited, Changed: true})
+
+	var id string
+	read(app, func() { id = app.Workspace.Current() })
+
+	// the source is in IndexedDB, not in the state in local storage
+	var data models.WorkspaceData
+	if found, err := app.Storage.Find("workspace-"+id, &data); err != nil || !found {
+		t.Fatalf("workspace not saved: %v", err)
+	}
+	if data.Source != nil {
+		t.Fatalf("source saved in local storage: %v", data.Source)
+	}
+	records, err := db.Prefix(filesStore, id+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, map[string]string{id + "/main\x00main.go": testEdited}) {
+		t.Fatalf("unexpected records %q", records)
+	}
+
+	var found bool
+	read(app, func() { data, found, err = app.Workspace.Data() })
+	if err != nil || !found {
+		t.Fatalf("workspace not found: %v", err)
+	}
+	if got := data.Source["main"]["main.go"]; got != testEdited {
+		t.Fatalf("read source %q", got)
+	}
+}
+
+func TestDefaultFile(t *testing.T) {
+	// the imports have no archives, so only errors in the file itself are reported
+	source := map[string]map[string]string{"main": {"main.go": defaultFile}}
+	none := func(path string) *compiler.Archive { return nil }
+	if errs := builderjs.Check("main", source, nil, none, map[string]*types.Package{}); len(errs) > 0 {
+		t.Fatalf("default file doesn't type-check: %v", errs)
+	}
+}
+
+// newTestApp returns an app that uses fakes for the browser and the compile server
+func newTestApp(b backend.Backend) *App {
+	app := &App{
+		Document: &fakeDocument{},
+		Storage:  &fakeStorage{values: map[string]string{}},
+		Location: &fakeLocation{url: &url.URL{Scheme: "https", Host: "play.jsgo.io", Path: "/"}},
+		Runner:   &fakeRunner{programs: make(chan Program, 1)},
+		extra:    []flux.StoreInterface{inspector{}},
+	}
+	app.Init()
+	app.Backend.Set(b)
+	return app
+}
+
+// inspect is handled by inspector, which runs F. The dispatcher handles one action at a time, so F
+// can read the stores without racing with the stores handling other actions.
+type inspect struct{ F func() }
+
+type inspector struct{}
+
+func (inspector) Handle(payload *flux.Payload) bool {
+	if a, ok := payload.Action.(*inspect); ok {
+		a.F()
+	}
+	return true
+}
+
+// read runs f between actions
+func read(app *App, f func()) {
+	<-app.Dispatch(&inspect{F: f})
+}
+
+type fakeDocument struct {
+	sync.Mutex
+	status string
+}
+
+func (d *fakeDocument) Status() string {
+	d.Lock()
+	defer d.Unlock()
+	return d.status
+}
+
+func (d *fakeDocument) SetStatus(message string) {
+	d.Lock()
+	defer d.Unlock()
+	d.status = message
+}
+
+func (d *fakeDocument) Meta(name string) string { return "" }
+
+func (d *fakeDocument) Debug(message ...interface{}) {}
+
+// fakeStorage is local storage and IndexedDB in memory. Values in local storage are JSON encoded, as
+// they are in the browser.
+type fakeStorage struct {
+	sync.Mutex
+	values    map[string]string
+	databases map[string]*fakeDatabase
+}
+
+func (s *fakeStorage) Find(key string, value interface{}) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	v, ok := s.values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal([]byte(v), value)
+}
+
+func (s *fakeStorage) Save(key string, value interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.values[key] = string(b)
+	return nil
+}
+
+func (s *fakeStorage) Delete(key string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.values, key)
+	return nil
+}
+
+func (s *fakeStorage) Watch(f func(key, value string, deleted bool)) {}
+
+func (s *fakeStorage) Database(name string, version int, stores ...string) (Database, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.databases == nil {
+		s.databases = map[string]*fakeDatabase{}
+	}
+	if s.databases[name] == nil {
+		s.databases[name] = &fakeDatabase{stores: map[string]map[string]interface{}{}}
+	}
+	d := s.databases[name]
+	d.Lock()
+	defer d.Unlock()
+	for _, store := range stores {
+		if d.stores[store] == nil {
+			d.stores[store] = map[string]interface{}{}
+		}
+	}
+	return d, nil
+}
+
+// fakeDatabase is an IndexedDB database in memory. Values are strings or byte slices.
+type fakeDatabase struct {
+	sync.Mutex
+	stores map[string]map[string]interface{}
+}
+
+func (d *fakeDatabase) records(store, prefix string) (map[string]interface{}, error) {
+	d.Lock()
+	defer d.Unlock()
+	s, ok := d.stores[store]
+	if !ok {
+		return nil, fmt.Errorf("object store %s not found", store)
+	}
+	records := map[string]interface{}{}
+	for key, value := range s {
+		if strings.HasPrefix(key, prefix) {
+			records[key] = value
+		}
+	}
+	return records, nil
+}
+
+func (d *fakeDatabase) update(store string, put map[string]interface{}, del []string) error {
+	d.Lock()
+	defer d.Unlock()
+	s, ok := d.stores[store]
+	if !ok {
+		return fmt.Errorf("object store %s not found", store)
+	}
+	for _, key := range del {
+		delete(s, key)
+	}
+	for key, value := range put {
+		s[key] = value
+	}
+	return nil
+}
+
+func (d *fakeDatabase) Prefix(store, prefix string) (map[string]string, error) {
+	records, err := d.records(store, prefix)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for key, value := range records {
+		values[key] = value.(string)
+	}
+	return values, nil
+}
+
+func (d *fakeDatabase) Update(store string, put map[string]string, del []string) error {
+	values := map[string]interface{}{}
+	for key, value := range put {
+		values[key] = value
+	}
+	return d.update(store, values, del)
+}
+
+func (d *fakeDatabase) PrefixBytes(store, prefix string) (map[string][]byte, error) {
+	records, err := d.records(store, prefix)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string][]byte{}
+	for key, value := range records {
+		values[key] = value.([]byte)
+	}
+	return values, nil
+}
+
+func (d *fakeDatabase) UpdateBytes(store string, put map[string][]byte, del []string) error {
+	values := map[string]interface{}{}
+	for key, value := range put {
+		// the browser stores a copy
+		values[key] = append([]byte(nil), value...)
+	}
+	return d.update(store, values, del)
+}
+
+func (d *fakeDatabase) DeletePrefix(store, prefix string) error {
+	records, err := d.records(store, prefix)
+	if err != nil {
+		return err
+	}
+	var del []string
+	for key := range records {
+		del = append(del, key)
+	}
+	return d.update(store, nil, del)
+}
+
+type fakeLocation struct {
+	sync.Mutex
+	url      *url.URL
+	replaced []string
+}
+
+func (l *fakeLocation) URL() *url.URL {
+	l.Lock()
+	defer l.Unlock()
+	u := *l.url
+	return &u
+}
+
+func (l *fakeLocation) Replace(path string) {
+	l.Lock()
+	defer l.Unlock()
+	l.url.Path = path
+	l.replaced = append(l.replaced, path)
+}
+
+func (l *fakeLocation) Replaced() []string {
+	l.Lock()
+	defer l.Unlock()
+	return l.replaced
+}
+
+// fakeRunner sends the programs that are run to programs
+type fakeRunner struct {
+	programs chan Program
+}
+
+func (r *fakeRunner) Run(program Program) error {
+	r.programs <- program
+	return nil
+}
+
+func (r *fakeRunner) Stop() {}
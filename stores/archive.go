@@ -1,25 +1,28 @@
 package stores
 
 import (
-	"context"
+	"bytes"
 	"fmt"
-	"go/types"
+	"strings"
 
 	"encoding/gob"
 
 	"errors"
 
-	"net/http"
-
 	"sync"
 
+	"io"
 	"io/ioutil"
 
+	"time"
+
 	"github.com/dave/flux"
 	"github.com/dave/jsgo/config"
 	"github.com/dave/play/actions"
 	"github.com/dave/play/models"
+	"github.com/dave/play/stores/backend"
 	"github.com/dave/play/stores/builderjs"
+	"github.com/dave/play/stores/worker"
 	"github.com/dave/services/deployer/deployermsg"
 	"github.com/gopherjs/gopherjs/compiler"
 )
@@ -33,7 +36,17 @@ type ArchiveStore struct {
 	// index (path -> item) of the previously received update
 	index deployermsg.ArchiveIndex
 
+	// db persists the cache across page loads. Items are keyed by "<path>@<hash>". This is nil if
+	// IndexedDB isn't available.
+	db Database
+
+	// worker compiles the source packages
+	worker *worker.Client
+
 	wait sync.WaitGroup
+
+	// mutex guards cache and index: archives are added to the cache by the download goroutines
+	mutex sync.Mutex
 }
 
 type CacheItem struct {
@@ -44,79 +57,54 @@ type CacheItem struct {
 
 func NewArchiveStore(app *App) *ArchiveStore {
 	s := &ArchiveStore{
-		app:   app,
-		cache: map[string]CacheItem{},
+		app:    app,
+		cache:  map[string]CacheItem{},
+		worker: worker.NewClient(),
 	}
 	return s
 }
 
-type Dep struct {
-	Path string
-	Js   []byte
+// Compile compiles the main package path and returns the JS of all the dependencies in the order
+// they should be loaded. The compile runs in the worker, and progress is called with the path of
+// each package as it's compiled.
+func (s *ArchiveStore) Compile(path string, tags []string, progress func(string)) ([]models.Dep, error) {
+	imports := map[string][]string{}
+	for p := range s.app.Source.Source() {
+		imports[p] = s.app.Scanner.Imports(p)
+	}
+	return s.worker.Compile(worker.Request{
+		Path:    path,
+		Source:  copySource(s.app.Source.Source()),
+		Tags:    tags,
+		Minify:  s.app.Page.Minify(),
+		Imports: imports,
+	}, s.archives(), progress)
 }
 
-func (s *ArchiveStore) Compile(path string, tags []string) ([]Dep, error) {
-	done := make(map[string]bool)
-	archives := map[string]*compiler.Archive{}
-	packages := map[string]*types.Package{}
-	jsdeps := []Dep{
-		// Always start with the prelude
-		{Path: "prelude", Js: s.cache["prelude"].Js},
-	}
-	var deps []*compiler.Archive
-	var compile func(path string) error
-	compile = func(path string) error {
-		if done[path] {
-			return nil
-		}
-		if s.app.Source.HasPackage(path) {
-			for _, imp := range s.app.Scanner.Imports(path) {
-				if err := compile(imp); err != nil {
-					return err
-				}
-			}
-			archive, err := builderjs.BuildPackage(
-				path,
-				s.app.Source.Source(),
-				tags,
-				deps,
-				s.app.Page.Minify(),
-				archives,
-				packages,
-			)
-			if err != nil {
-				return err
-			}
-			js, _, err := builderjs.GetPackageCode(context.Background(), archive, false, true)
-			if err != nil {
-				return err
-			}
-			jsdeps = append(jsdeps, Dep{path, js})
-			deps = append(deps, archive)
-			done[path] = true
-			return nil
-		}
-		item, ok := s.cache[path]
-		if !ok {
-			return fmt.Errorf("%s not found", path)
-		}
-		for _, imp := range item.Archive.Imports {
-			if err := compile(imp); err != nil {
-				return err
-			}
-		}
-		jsdeps = append(jsdeps, Dep{path, item.Js})
-		deps = append(deps, item.Archive)
-		done[path] = true
-		return nil
-	}
-	if err := compile("runtime"); err != nil {
-		return nil, err
-	}
-	if err := compile(path); err != nil {
-		return nil, err
+// CompileTest compiles the tests for the source package path, returning the dependencies of the
+// generated test main package (builderjs.TestMainPath).
+func (s *ArchiveStore) CompileTest(path string, tags []string, progress func(string)) ([]models.Dep, error) {
+	return s.worker.Compile(worker.Request{
+		Test:   path,
+		Source: copySource(s.app.Source.Source()),
+		Tags:   tags,
+		Minify: s.app.Page.Minify(),
+	}, s.archives(), progress)
+}
+
+// CancelCompile stops a running compile. Compile returns worker.ErrCancelled.
+func (s *ArchiveStore) CancelCompile() {
+	s.worker.Cancel()
+}
+
+func (s *ArchiveStore) archives() map[string]worker.Archive {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	archives := map[string]worker.Archive{}
+	for path, item := range s.cache {
+		archives[path] = worker.Archive{Path: path, Hash: item.Hash, Archive: item.Archive, Js: item.Js}
 	}
-	return jsdeps, nil
+	return archives
 }
 
 func (s *ArchiveStore) AllFresh() bool {
@@ -130,6 +118,32 @@ func (s *ArchiveStore) AllFresh() bool {
 
 // Fresh is true if current cache matches the previously downloaded archives
 func (s *ArchiveStore) Fresh(mainPath string) bool {
+	return s.fresh(s.app.Scanner.Imports(mainPath), s.app.Source.Source())
+}
+
+// FreshTest is true if the archives needed to compile the tests for path have been downloaded
+func (s *ArchiveStore) FreshTest(path string) bool {
+	tags := s.app.Compile.Tags()
+	source, err := builderjs.TestSource(path, s.app.Source.Source(), tags)
+	if err != nil {
+		// the error will be reported when the tests are compiled
+		return true
+	}
+	var imports []string
+	for _, p := range []string{path, path + "_test", builderjs.TestMainPath} {
+		imps, err := builderjs.Imports(source[p], tags, p != builderjs.TestMainPath)
+		if err != nil {
+			return true
+		}
+		imports = append(imports, imps...)
+	}
+	return s.fresh(imports, source)
+}
+
+func (s *ArchiveStore) fresh(imports []string, source map[string]map[string]string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	// if index is nil, either the page has just loaded or we're in the middle of an update
 	if s.index == nil {
 		return false
@@ -148,9 +162,9 @@ func (s *ArchiveStore) Fresh(mainPath string) bool {
 	}
 
 	// then check that all the imports in all packages are found in the index, or in the source
-	for _, path := range s.app.Scanner.Imports(mainPath) {
+	for _, path := range imports {
 		_, inIndex := s.index[path]
-		_, inSource := s.app.Source.Source()[path]
+		_, inSource := source[path]
 		if !inIndex && !inSource {
 			return false
 		}
@@ -159,8 +173,15 @@ func (s *ArchiveStore) Fresh(mainPath string) bool {
 	return true
 }
 
+// Cache returns a copy of the cache (path -> item)
 func (s *ArchiveStore) Cache() map[string]CacheItem {
-	return s.cache
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cache := make(map[string]CacheItem, len(s.cache))
+	for path, item := range s.cache {
+		cache[path] = item
+	}
+	return cache
 }
 
 func (s *ArchiveStore) CacheStrings() map[string]string {
@@ -171,17 +192,106 @@ func (s *ArchiveStore) CacheStrings() map[string]string {
 	return hashes
 }
 
+// load reads the cache persisted by previous page loads
+func (s *ArchiveStore) load() error {
+	db, err := s.app.Storage.Database("play-archives", 1, archivesStore)
+	if err != nil {
+		return err
+	}
+	s.db = db
+	records, err := s.db.PrefixBytes(archivesStore, "")
+	if err != nil {
+		return err
+	}
+	var invalid []string
+	for key, b := range records {
+		var c CacheItem
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&c); err != nil {
+			// probably saved by an older version
+			invalid = append(invalid, key)
+			continue
+		}
+		s.mutex.Lock()
+		s.cache[key[:strings.LastIndex(key, "@")]] = c
+		s.mutex.Unlock()
+	}
+	return s.db.UpdateBytes(archivesStore, nil, invalid)
+}
+
+// persist saves a cache item, replacing the previous version of the package
+func (s *ArchiveStore) persist(path string, previous, c CacheItem) error {
+	if s.db == nil {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(c); err != nil {
+		return err
+	}
+	var del []string
+	if previous.Hash != "" && previous.Hash != c.Hash {
+		del = append(del, path+"@"+previous.Hash)
+	}
+	return s.db.UpdateBytes(archivesStore, map[string][]byte{path + "@" + c.Hash: buf.Bytes()}, del)
+}
+
+func (s *ArchiveStore) setIndex(index deployermsg.ArchiveIndex) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.index = index
+}
+
+const archivesStore = "archives"
+
+// fetch downloads a file from the package host and reads it with read. Temporary errors are retried
+// with exponential backoff, so one failed request doesn't fail the whole update.
+func (s *ArchiveStore) fetch(name string, read func(r io.Reader) error) error {
+	for attempt := 1; ; attempt++ {
+		err := func() error {
+			body, err := s.app.Backend.Backend().Fetch(config.Pkg, name)
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+			return read(body)
+		}()
+		if err == nil || attempt > maxRetries || !backend.Temporary(err) {
+			return err
+		}
+		delay := backoff(attempt)
+		s.app.Debug(fmt.Sprintf("Fetching %s failed, retrying in %v:", name, delay), err.Error())
+		<-time.After(delay)
+	}
+}
+
 func (s *ArchiveStore) Handle(payload *flux.Payload) bool {
 	switch a := payload.Action.(type) {
+	case *actions.Load:
+		if err := s.load(); err != nil {
+			// the cache is only an optimisation, so carry on without it
+			s.app.Warn(models.StorageSource, fmt.Errorf("archives will not be cached: %v", err))
+		}
+	case *actions.ChangeServer:
+		// archives from the new server must be requested before running
+		payload.Wait(s.app.Backend)
+		s.setIndex(nil)
+	case *actions.ModuleChanged:
+		// the required versions may have changed, so the archives must be requested before running
+		s.setIndex(nil)
 	case *actions.MinifyToggleClick:
 		payload.Wait(s.app.Page)
-		s.index = nil
+		s.setIndex(nil)
 		s.app.Dispatch(&actions.RequestStart{Type: models.UpdateRequest, Run: false})
 	case *actions.LoadSource:
 		payload.Wait(s.app.Scanner)
 		if a.Update && !s.AllFresh() {
 			s.app.Dispatch(&actions.RequestStart{Type: models.UpdateRequest, Run: false})
 		}
+	case *actions.RequestOpen:
+		if a.Type == models.UpdateRequest {
+			// if the connection was lost, the request is sent again when it's reopened, so the
+			// archives still downloading must be in the cache before it's built
+			s.wait.Wait()
+		}
 	case *actions.RequestMessage:
 		switch message := a.Message.(type) {
 		case deployermsg.Archive:
@@ -199,34 +309,40 @@ func (s *ArchiveStore) Handle(payload *flux.Payload) bool {
 						// prelude doesn't have an archive file
 						return
 					}
-					resp, err := http.Get(fmt.Sprintf("%s://%s/%s.%s.ax", config.Protocol[config.Pkg], config.Host[config.Pkg], message.Path, message.Hash))
-					if err != nil {
-						s.app.Fail(err)
-						return
-					}
 					var a compiler.Archive
-					if err := gob.NewDecoder(resp.Body).Decode(&a); err != nil {
-						s.app.Fail(err)
+					if err := s.fetch(fmt.Sprintf("%s.%s.ax", message.Path, message.Hash), func(r io.Reader) error {
+						a = compiler.Archive{}
+						return gob.NewDecoder(r).Decode(&a)
+					}); err != nil {
+						s.app.Fail(models.NetworkSource, err)
 						return
 					}
 					c.Archive = &a
 				}()
 				go func() {
 					defer getwait.Done()
-					resp, err := http.Get(fmt.Sprintf("%s://%s/%s.%s.js", config.Protocol[config.Pkg], config.Host[config.Pkg], message.Path, message.Hash))
-					if err != nil {
-						s.app.Fail(err)
+					if err := s.fetch(fmt.Sprintf("%s.%s.js", message.Path, message.Hash), func(r io.Reader) error {
+						js, err := ioutil.ReadAll(r)
+						c.Js = js
+						return err
+					}); err != nil {
+						c.Js = nil
+						s.app.Fail(models.NetworkSource, err)
 						return
 					}
-					js, err := ioutil.ReadAll(resp.Body)
-					if err != nil {
-						s.app.Fail(err)
-						return
-					}
-					c.Js = js
 				}()
 				getwait.Wait()
+				if c.Js == nil || (c.Archive == nil && message.Path != "prelude") {
+					// the error has been reported
+					return
+				}
+				s.mutex.Lock()
+				previous := s.cache[message.Path]
 				s.cache[message.Path] = c
+				s.mutex.Unlock()
+				if err := s.persist(message.Path, previous, c); err != nil {
+					s.app.Warn(models.StorageSource, fmt.Errorf("error caching archive: %v", err))
+				}
 				if message.Path == "prelude" {
 					// prelude doesn't have an archive file
 					s.app.Log("prelude")
@@ -236,7 +352,7 @@ func (s *ArchiveStore) Handle(payload *flux.Payload) bool {
 			}()
 			return true
 		case deployermsg.ArchiveIndex:
-			s.index = message
+			s.setIndex(message)
 		}
 	case *actions.RequestClose:
 
@@ -247,15 +363,18 @@ func (s *ArchiveStore) Handle(payload *flux.Payload) bool {
 
 		s.wait.Wait()
 
-		if !s.AllFresh() {
-			s.app.Fail(errors.New("websocket closed but archives not updated"))
+		if !s.AllFresh() || (a.Test && !s.FreshTest(a.Path)) {
+			s.app.Fail(models.NetworkSource, errors.New("websocket closed but archives not updated"))
 			return true
 		}
 
 		if a.Run {
 			s.app.Dispatch(&actions.CompileStart{})
+		} else if a.Test {
+			s.app.Dispatch(&actions.TestStart{Path: a.Path})
 		} else {
 			var downloaded, unchanged int
+			// index is only changed by Handle, so it doesn't need locking here
 			for _, v := range s.index {
 				if v.Unchanged {
 					unchanged++
@@ -0,0 +1,119 @@
+package stores
+
+import (
+	"strings"
+
+	"github.com/dave/flux"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores/backend"
+)
+
+func NewBackendStore(app *App) *BackendStore {
+	s := &BackendStore{
+		app: app,
+	}
+	return s
+}
+
+// BackendStore selects the compile server. In order of precedence, the server URL is set by the
+// "server" query parameter, the server settings modal (persisted in local storage) or the
+// "play-server" meta tag. If none are set the server that serves the page is used (see
+// backend.Default). The query parameter only applies to the page load, so a link can't change the
+// server of later sessions.
+type BackendStore struct {
+	app *App
+
+	backend backend.Backend
+	url     string // url of the self-hosted server, or "" for default
+	saved   bool   // url is saved in local storage
+}
+
+func (s *BackendStore) Backend() backend.Backend {
+	if s.backend == nil {
+		s.backend = s.defaultBackend()
+	}
+	return s.backend
+}
+
+// Url is the URL of the self-hosted server, or "" if the default servers are being used
+func (s *BackendStore) Url() string {
+	return s.url
+}
+
+// Saved is true if the server is saved in local storage. A server from the query parameter isn't
+// saved until it's confirmed in the server settings modal.
+func (s *BackendStore) Saved() bool {
+	return s.saved
+}
+
+// Set replaces the backend (used to connect to an in-process fake in tests)
+func (s *BackendStore) Set(b backend.Backend) {
+	s.backend = b
+}
+
+func (s *BackendStore) Handle(payload *flux.Payload) bool {
+	switch a := payload.Action.(type) {
+	case *actions.Load:
+		var saved string
+		if _, err := s.app.Storage.Find("server", &saved); err != nil {
+			s.app.Fail(models.StorageSource, err)
+			return true
+		}
+		query := s.app.Location.URL().Query()
+		meta := s.app.Document.Meta("play-server")
+		switch {
+		case query.Get("server") != "":
+			// not saved, so following a link doesn't send the source of later sessions elsewhere
+			if err := s.change(query.Get("server"), false); err != nil {
+				s.app.Fail(models.NetworkSource, err)
+				return true
+			}
+		case saved != "":
+			if err := s.change(saved, false); err != nil {
+				s.app.Fail(models.NetworkSource, err)
+				return true
+			}
+			s.saved = true
+		case meta != "":
+			if err := s.change(meta, false); err != nil {
+				s.app.Fail(models.NetworkSource, err)
+				return true
+			}
+		}
+		payload.Notify()
+	case *actions.ChangeServer:
+		if err := s.change(a.Url, true); err != nil {
+			s.app.Fail(models.NetworkSource, err)
+			return true
+		}
+		payload.Notify()
+	}
+	return true
+}
+
+func (s *BackendStore) change(u string, save bool) error {
+	u = strings.TrimSpace(u)
+	if u == "" {
+		s.backend = s.defaultBackend()
+	} else {
+		b, err := backend.New(u)
+		if err != nil {
+			return err
+		}
+		s.backend = b
+	}
+	s.url = u
+	s.saved = u != "" && save
+	if save {
+		if u == "" {
+			return s.app.Storage.Delete("server")
+		}
+		return s.app.Storage.Save("server", u)
+	}
+	return nil
+}
+
+func (s *BackendStore) defaultBackend() backend.Backend {
+	return backend.Default(s.app.Location.URL())
+}
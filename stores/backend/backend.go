@@ -0,0 +1,70 @@
+// Package backend abstracts the compile server that the playground talks to.
+package backend
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dave/play/models"
+	"github.com/dave/services"
+)
+
+// Backend is a compile server that speaks the play protocol (see
+// github.com/dave/jsgo/server/play/messages), and the stores that serve the files it creates.
+type Backend interface {
+	// Dial opens a connection to the server. Operations (get, update, initialise, share or deploy)
+	// are multiplexed over the connection: each is sent with an ID, and the server sends the ID with
+	// each response. The handler functions are called in new goroutines.
+	Dial(handler Handler) (Conn, error)
+
+	// Fetch downloads a file. host is one of config.Pkg (compiled archives and JS), config.Src
+	// (shared source) or config.Index (deployed pages).
+	Fetch(host, name string) (io.ReadCloser, error)
+
+	// URL returns the public URL of a file. See Fetch for host.
+	URL(host, name string) string
+
+	// Wasm compiles a main package to WebAssembly with the go command. Only self-hosted servers
+	// support this.
+	Wasm(request models.WasmRequest) (*models.Wasm, error)
+}
+
+// WasmHost is the host of the WebAssembly compiler of a self-hosted server, which also serves
+// wasm_exec.js.
+const WasmHost = "wasm"
+
+// Handler receives the events from a connection. Message is called with each response to operation
+// id, and Done when the server has finished it.
+type Handler struct {
+	Open    func()
+	Message func(id int, message services.Message)
+	Done    func(id int)
+	Close   func()
+	Error   func(err error)
+}
+
+// Conn is an open connection to the server
+type Conn interface {
+	// Send sends a message for operation id
+	Send(id int, message services.Message) error
+	Close() error
+}
+
+// StatusError is returned by Fetch when the host responds with an error status
+type StatusError struct {
+	Code int
+	Name string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error %d fetching %s", e.Code, e.Name)
+}
+
+// Temporary is true if a request that failed with err may succeed when it's retried. Server errors
+// (5xx) and failed requests are temporary, but other errors (e.g. a missing file) are not.
+func Temporary(err error) bool {
+	if e, ok := err.(*StatusError); ok {
+		return e.Code >= 500 || e.Code == 429
+	}
+	return true
+}
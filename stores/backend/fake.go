@@ -0,0 +1,92 @@
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/dave/play/models"
+	"github.com/dave/services"
+)
+
+// Fake is an in-process backend for testing. Serve is called in a new goroutine with each message
+// sent, and the operation is done when it returns. If it returns an error, the connection fails.
+type Fake struct {
+	Serve func(message services.Message, send func(services.Message)) error
+	Files map[string]map[string][]byte // Files served by Fetch: host -> name -> contents
+
+	// FetchError is called before each fetch. If it returns an error, the fetch fails with it.
+	FetchError func(host, name string) error
+
+	// CompileWasm is called by Wasm. If it's nil, Wasm returns an error.
+	CompileWasm func(request models.WasmRequest) (*models.Wasm, error)
+}
+
+func (f *Fake) URL(host, name string) string {
+	return fmt.Sprintf("fake://%s/%s", host, name)
+}
+
+func (f *Fake) Fetch(host, name string) (io.ReadCloser, error) {
+	if f.FetchError != nil {
+		if err := f.FetchError(host, name); err != nil {
+			return nil, err
+		}
+	}
+	b, ok := f.Files[host][name]
+	if !ok {
+		return nil, &StatusError{Code: 404, Name: name}
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (f *Fake) Wasm(request models.WasmRequest) (*models.Wasm, error) {
+	if f.CompileWasm == nil {
+		return nil, fmt.Errorf("WebAssembly not supported")
+	}
+	return f.CompileWasm(request)
+}
+
+func (f *Fake) Dial(handler Handler) (Conn, error) {
+	c := &fakeConn{fake: f, handler: handler}
+	go handler.Open()
+	return c, nil
+}
+
+type fakeConn struct {
+	fake    *Fake
+	handler Handler
+
+	sync.Mutex
+	closed bool
+}
+
+func (c *fakeConn) Send(id int, message services.Message) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return fmt.Errorf("connection closed")
+	}
+	go func() {
+		err := c.fake.Serve(message, func(m services.Message) { c.handler.Message(id, m) })
+		if err != nil {
+			c.handler.Error(err)
+			c.Close()
+			return
+		}
+		c.handler.Done(id)
+	}()
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	go c.handler.Close()
+	return nil
+}
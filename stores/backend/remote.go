@@ -0,0 +1,264 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dave/jsgo/config"
+	"github.com/dave/jsgo/server/play/messages"
+	"github.com/dave/play/models"
+	"github.com/dave/services"
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/gopherjs/websocket/websocketjs"
+)
+
+// Remote is a compile server reached over a websocket, with files served over http.
+type Remote struct {
+	// Socket is the websocket URL of the play handler
+	Socket string
+	// Hosts maps config.Pkg, config.Src, config.Index and WasmHost (self-hosted servers only) to
+	// base URLs
+	Hosts map[string]string
+
+	// legacy is set (atomically) if the server doesn't support models.Envelope. Each operation is
+	// then sent on its own websocket without an envelope, and is done when the server closes it.
+	legacy int32
+}
+
+// Default returns the self-hosted server that serves the page (e.g. playserver -static). The jsgo.io
+// compile server has been shut down, so there's no public server to fall back to. If the page isn't
+// served over http (e.g. it's opened from a file), playserver's default address is used.
+func Default(page *url.URL) *Remote {
+	r, err := New(page.Scheme + "://" + page.Host)
+	if err != nil {
+		r, _ = New("http://localhost:8080")
+	}
+	return r
+}
+
+// New returns a self-hosted server at base (e.g. http://localhost:8080). The websocket handler is
+// at /_play/, files are served from /_pkg/, /_src/ and /_index/, and the WebAssembly compiler is at
+// /_wasm/.
+func New(base string) (*Remote, error) {
+	u, err := url.Parse(strings.TrimSuffix(base, "/"))
+	if err != nil {
+		return nil, err
+	}
+	r := &Remote{
+		Hosts: map[string]string{},
+	}
+	switch u.Scheme {
+	case "http":
+		r.Socket = "ws://" + u.Host + u.Path + "/_play/"
+	case "https":
+		r.Socket = "wss://" + u.Host + u.Path + "/_play/"
+	default:
+		return nil, fmt.Errorf("server URL %s must start with http:// or https://", base)
+	}
+	for _, host := range []string{config.Pkg, config.Src, config.Index, WasmHost} {
+		r.Hosts[host] = u.String() + "/_" + host
+	}
+	return r, nil
+}
+
+func (r *Remote) URL(host, name string) string {
+	return r.Hosts[host] + "/" + name
+}
+
+func (r *Remote) Fetch(host, name string) (io.ReadCloser, error) {
+	resp, err := http.Get(r.URL(host, name))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, &StatusError{Code: resp.StatusCode, Name: name}
+	}
+	return resp.Body, nil
+}
+
+func (r *Remote) Wasm(request models.WasmRequest) (*models.Wasm, error) {
+	base, ok := r.Hosts[WasmHost]
+	if !ok {
+		return nil, errors.New("the WebAssembly target needs a self-hosted server (see the Server option)")
+	}
+	b, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Post(base+"/build", "application/json", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		// the body is the error message, e.g. the output of go build
+		message, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("error %d compiling to WebAssembly: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	binary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	body, err := r.Fetch(WasmHost, "wasm_exec.js")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	support, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Wasm{Binary: binary, Support: support}, nil
+}
+
+func (r *Remote) Dial(handler Handler) (Conn, error) {
+	if atomic.LoadInt32(&r.legacy) == 1 {
+		c := &legacyConn{remote: r, handler: handler, sockets: map[int]*websocketjs.WebSocket{}}
+		go handler.Open()
+		return c, nil
+	}
+	ws, err := websocketjs.New(r.Socket)
+	if err != nil {
+		return nil, err
+	}
+	ws.AddEventListener("open", false, func(ev *js.Object) {
+		go handler.Open()
+	})
+	ws.AddEventListener("message", false, func(ev *js.Object) {
+		go func() {
+			var e models.Envelope
+			if err := json.Unmarshal([]byte(ev.Get("data").String()), &e); err != nil {
+				handler.Error(err)
+				return
+			}
+			if e.ID == 0 {
+				// The server didn't echo the operation ID, so it doesn't support envelopes. The
+				// connection is lost, and when it's dialed again the operations are sent again,
+				// each on its own connection.
+				atomic.StoreInt32(&r.legacy, 1)
+				handler.Error(fmt.Errorf("server %s doesn't support multiplexed connections", r.Socket))
+				return
+			}
+			if e.Done {
+				handler.Done(e.ID)
+				return
+			}
+			m, err := messages.Unmarshal(e.Message)
+			if err != nil {
+				handler.Error(err)
+				return
+			}
+			handler.Message(e.ID, m)
+		}()
+	})
+	ws.AddEventListener("close", false, func(ev *js.Object) {
+		go handler.Close()
+	})
+	ws.AddEventListener("error", false, func(ev *js.Object) {
+		go handler.Error(fmt.Errorf("error from server %s", r.Socket))
+	})
+	return &remoteConn{ws: ws}, nil
+}
+
+type remoteConn struct {
+	ws *websocketjs.WebSocket
+}
+
+func (c *remoteConn) Send(id int, message services.Message) error {
+	b, _, err := messages.Marshal(message)
+	if err != nil {
+		return err
+	}
+	b, err = json.Marshal(models.Envelope{ID: id, Message: b})
+	if err != nil {
+		return err
+	}
+	return c.ws.Send(string(b))
+}
+
+func (c *remoteConn) Close() error {
+	return c.ws.Close()
+}
+
+// legacyConn is a connection to a server that doesn't support envelopes. Each operation opens a
+// websocket, sends its message and receives the responses until the server closes it.
+type legacyConn struct {
+	remote  *Remote
+	handler Handler
+
+	sync.Mutex
+	sockets map[int]*websocketjs.WebSocket
+	closed  bool
+}
+
+func (c *legacyConn) Send(id int, message services.Message) error {
+	b, _, err := messages.Marshal(message)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return errors.New("connection closed")
+	}
+	ws, err := websocketjs.New(c.remote.Socket)
+	if err != nil {
+		return err
+	}
+	c.sockets[id] = ws
+	ws.AddEventListener("open", false, func(ev *js.Object) {
+		if err := ws.Send(string(b)); err != nil {
+			go c.handler.Error(err)
+		}
+	})
+	ws.AddEventListener("message", false, func(ev *js.Object) {
+		go func() {
+			m, err := messages.Unmarshal([]byte(ev.Get("data").String()))
+			if err != nil {
+				c.handler.Error(err)
+				return
+			}
+			c.handler.Message(id, m)
+		}()
+	})
+	ws.AddEventListener("close", false, func(ev *js.Object) {
+		go func() {
+			c.Lock()
+			_, open := c.sockets[id]
+			delete(c.sockets, id)
+			c.Unlock()
+			if open {
+				c.handler.Done(id)
+			}
+		}()
+	})
+	ws.AddEventListener("error", false, func(ev *js.Object) {
+		go c.handler.Error(fmt.Errorf("error from server %s", c.remote.Socket))
+	})
+	return nil
+}
+
+func (c *legacyConn) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	for id, ws := range c.sockets {
+		delete(c.sockets, id)
+		ws.Close()
+	}
+	go c.handler.Close()
+	return nil
+}
@@ -0,0 +1,95 @@
+package stores
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/dave/locstor"
+	"github.com/dave/play/stores/idb"
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// browserDocument shows the status in the #message element
+type browserDocument struct{}
+
+func (browserDocument) Status() string {
+	return dom.GetWindow().Document().GetElementByID("message").InnerHTML()
+}
+
+func (browserDocument) SetStatus(message string) {
+	if message != "" {
+		js.Global.Get("console").Call("log", "Status", strconv.Quote(message))
+	}
+	requestAnimationFrame()
+	dom.GetWindow().Document().GetElementByID("message").SetInnerHTML(message)
+	requestAnimationFrame()
+}
+
+func (browserDocument) Meta(name string) string {
+	m := dom.GetWindow().Document().QuerySelector(`meta[name="` + name + `"]`)
+	if m == nil {
+		return ""
+	}
+	return m.GetAttribute("content")
+}
+
+func (browserDocument) Debug(message ...interface{}) {
+	js.Global.Get("console").Call("log", message...)
+}
+
+func requestAnimationFrame() {
+	c := make(chan struct{})
+	js.Global.Call("requestAnimationFrame", func() { close(c) })
+	<-c
+}
+
+// browserStorage is local storage and IndexedDB
+type browserStorage struct {
+	*locstor.DataStore
+}
+
+func newBrowserStorage() browserStorage {
+	return browserStorage{locstor.NewDataStore(locstor.JSONEncoding)}
+}
+
+// Watch uses the storage event, which local storage fires in all other tabs when a key is changed
+func (browserStorage) Watch(f func(key, value string, deleted bool)) {
+	dom.GetWindow().AddEventListener("storage", false, func(e dom.Event) {
+		key := e.Underlying().Get("key")
+		if key == nil {
+			// the storage was cleared
+			return
+		}
+		value := e.Underlying().Get("newValue")
+		if value == nil {
+			f(key.String(), "", true)
+			return
+		}
+		f(key.String(), value.String(), false)
+	})
+}
+
+func (browserStorage) Database(name string, version int, stores ...string) (Database, error) {
+	db, err := idb.Open(name, version, stores...)
+	if err != nil {
+		// a nil *idb.DB mustn't be returned as a non-nil Database
+		return nil, err
+	}
+	return db, nil
+}
+
+// browserLocation is the location of the window. It's replaced with the history API.
+type browserLocation struct{}
+
+func (browserLocation) URL() *url.URL {
+	u, err := url.Parse(dom.GetWindow().Location().Href)
+	if err != nil {
+		return &url.URL{Path: "/"}
+	}
+	return u
+}
+
+func (browserLocation) Replace(path string) {
+	js.Global.Get("history").Call("replaceState", js.M{}, "", path)
+}
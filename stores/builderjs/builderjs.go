@@ -18,10 +18,13 @@ import (
 
 	"github.com/dave/services/includer"
 	"github.com/gopherjs/gopherjs/compiler"
+	"github.com/neelance/sourcemap"
 	"golang.org/x/tools/go/gcexportdata"
 )
 
-func BuildPackage(path string, source map[string]map[string]string, tags []string, deps []*compiler.Archive, minify bool, archives map[string]*compiler.Archive, packages map[string]*types.Package) (*compiler.Archive, error) {
+// BuildPackage compiles the source package path. If test is true, the _test.go files in path are
+// included in the build.
+func BuildPackage(path string, source map[string]map[string]string, tags []string, deps []*compiler.Archive, minify, test bool, archives map[string]*compiler.Archive, packages map[string]*types.Package) (*compiler.Archive, error) {
 
 	for _, a := range deps {
 		if archives[a.ImportPath] == nil {
@@ -49,7 +52,7 @@ func BuildPackage(path string, source map[string]map[string]string, tags []strin
 			sourceFiles, ok := source[imp]
 			if ok {
 				// We have the source for this dep
-				archive, err := compileFiles(fset, imp, tags, sourceFiles, importContext, minify)
+				archive, err := compileFiles(fset, imp, tags, sourceFiles, importContext, minify, test && imp == path)
 				if err != nil {
 					return nil, err
 				}
@@ -67,11 +70,10 @@ func BuildPackage(path string, source map[string]map[string]string, tags []strin
 	return archive, nil
 }
 
-func compileFiles(fset *token.FileSet, path string, tags []string, sourceFiles map[string]string, importContext *compiler.ImportContext, minify bool) (*compiler.Archive, error) {
+func compileFiles(fset *token.FileSet, path string, tags []string, sourceFiles map[string]string, importContext *compiler.ImportContext, minify, test bool) (*compiler.Archive, error) {
 	var files []*ast.File
-	inc := includer.New(sourceFiles, tags)
 	for name, contents := range sourceFiles {
-		include, err := inc.Include(name)
+		include, err := includeFile(name, contents, tags, test)
 		if err != nil {
 			return nil, err
 		}
@@ -80,7 +82,7 @@ func compileFiles(fset *token.FileSet, path string, tags []string, sourceFiles m
 		}
 		f, err := parser.ParseFile(fset, name, contents, parser.ParseComments)
 		if err != nil {
-			return nil, err
+			return nil, &CompileError{Path: path, Errors: []error{err}}
 		}
 		files = append(files, f)
 	}
@@ -97,7 +99,10 @@ func compileFiles(fset *token.FileSet, path string, tags []string, sourceFiles m
 
 	archive, err := compiler.Compile(path, files, fset, importContext, minify)
 	if err != nil {
-		return nil, err
+		if list, ok := err.(compiler.ErrorList); ok {
+			return nil, &CompileError{Path: path, Errors: list}
+		}
+		return nil, &CompileError{Path: path, Errors: []error{err}}
 	}
 
 	for name, contents := range sourceFiles {
@@ -112,7 +117,77 @@ func compileFiles(fset *token.FileSet, path string, tags []string, sourceFiles m
 	return archive, nil
 }
 
+// CompileError is returned when the files in a source package fail to parse or type-check.
+type CompileError struct {
+	Path   string  // Path of the package
+	Errors []error // Errors are usually scanner.ErrorList or types.Error
+}
+
+func (e *CompileError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("%s: compile error", e.Path)
+	}
+	if len(e.Errors) == 1 {
+		return fmt.Sprintf("%s: %v", e.Path, e.Errors[0])
+	}
+	return fmt.Sprintf("%s: %v (and %d more errors)", e.Path, e.Errors[0], len(e.Errors)-1)
+}
+
+// includeFile returns true if the file should be included in the build. Test files are only
+// included if test is true, and are matched against the build tags using the name of the file with
+// the _test suffix removed (so foo_linux_test.go is treated as foo_linux.go).
+func includeFile(name, contents string, tags []string, test bool) (bool, error) {
+	if strings.HasSuffix(name, "_test.go") {
+		if !test {
+			return false, nil
+		}
+		name = strings.TrimSuffix(name, "_test.go") + ".go"
+	}
+	return includer.New(map[string]string{name: contents}, tags).Include(name)
+}
+
 func GetPackageCode(ctx context.Context, archive *compiler.Archive, minify, initializer bool) (contents []byte, hash []byte, err error) {
+	contents, err = writePackageCode(archive, minify, initializer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	sha := sha1.New()
+	if _, err := sha.Write(contents); err != nil {
+		return nil, nil, err
+	}
+	return contents, sha.Sum(nil), nil
+}
+
+// GetPackageCodeAndSourceMap returns the JS of the package (as GetPackageCode) and a source map for
+// it. The sources in the map are named "<import path>/<file name>".
+func GetPackageCodeAndSourceMap(ctx context.Context, archive *compiler.Archive, minify, initializer bool) (contents []byte, sourceMap []byte, err error) {
+	m := &sourcemap.Map{File: archive.ImportPath + ".js"}
+	contents, err = writePackageCode(archive, minify, initializer, func(generatedLine, generatedColumn int, originalPos token.Position) {
+		if !originalPos.IsValid() {
+			m.AddMapping(&sourcemap.Mapping{GeneratedLine: generatedLine, GeneratedColumn: generatedColumn})
+			return
+		}
+		m.AddMapping(&sourcemap.Mapping{
+			GeneratedLine:   generatedLine,
+			GeneratedColumn: generatedColumn,
+			OriginalFile:    archive.ImportPath + "/" + originalPos.Filename,
+			OriginalLine:    originalPos.Line,
+			OriginalColumn:  originalPos.Column,
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := m.WriteTo(buf); err != nil {
+		return nil, nil, err
+	}
+	return contents, buf.Bytes(), nil
+}
+
+// writePackageCode writes the JS of the package. If mapping is not nil, it's called with the
+// position in the Go source of each position in the JS.
+func writePackageCode(archive *compiler.Archive, minify, initializer bool, mapping func(generatedLine, generatedColumn int, originalPos token.Position)) ([]byte, error) {
 	dceSelection := make(map[*compiler.Decl]struct{})
 	for _, d := range archive.Declarations {
 		dceSelection[d] = struct{}{}
@@ -127,12 +202,26 @@ func GetPackageCode(ctx context.Context, archive *compiler.Archive, minify, init
 			s = `$load["%s"] = function () {` + "\n"
 		}
 		if _, err := fmt.Fprintf(buf, s, archive.ImportPath); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	}
 
-	if err := compiler.WritePkgCode(archive, dceSelection, minify, &compiler.SourceMapFilter{Writer: buf}); err != nil {
-		return nil, nil, err
+	filter := &compiler.SourceMapFilter{Writer: buf}
+	if mapping != nil {
+		// the lines of the filter are counted from the start of the package code, so are offset by
+		// the initializer
+		lines := bytes.Count(buf.Bytes(), []byte("\n"))
+		columns := buf.Len() - (bytes.LastIndex(buf.Bytes(), []byte("\n")) + 1)
+		filter.MappingCallback = func(generatedLine, generatedColumn int, originalPos token.Position) {
+			if generatedLine == 1 {
+				generatedColumn += columns
+			}
+			mapping(generatedLine+lines, generatedColumn, originalPos)
+		}
+	}
+
+	if err := compiler.WritePkgCode(archive, dceSelection, minify, filter); err != nil {
+		return nil, err
 	}
 
 	if minify {
@@ -153,13 +242,9 @@ func GetPackageCode(ctx context.Context, archive *compiler.Archive, minify, init
 			}
 		*/
 		if _, err := fmt.Fprint(buf, "};"); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	}
 
-	sha := sha1.New()
-	if _, err := sha.Write(buf.Bytes()); err != nil {
-		return nil, nil, err
-	}
-	return buf.Bytes(), sha.Sum(nil), nil
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,127 @@
+package builderjs
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/gopherjs/gopherjs/compiler"
+	"golang.org/x/tools/go/gcexportdata"
+)
+
+// Check type-checks the source package path and returns all the errors found. Imports that are
+// source packages are type-checked from source (errors in these are ignored), and all others are
+// loaded from the export data of the archive returned by archive. Packages loaded from export data
+// are added to packages, which can be reused by subsequent calls while the archives are unchanged.
+// Imports of packages that have no archive yet aren't reported, because they are valid until the
+// next update has downloaded the archives.
+func Check(path string, source map[string]map[string]string, tags []string, archive func(path string) *compiler.Archive, packages map[string]*types.Package) []error {
+	fset := token.NewFileSet()
+	checked := map[string]*types.Package{}
+	checking := map[string]bool{}
+
+	// imports (position of the import path -> path) of the parsed files, and the imported packages
+	// that have no archive
+	imports := map[token.Pos]string{}
+	missing := map[string]bool{}
+
+	var check func(path string, errorFunc func(error)) (*types.Package, error)
+	var imp importerFunc = func(path string) (*types.Package, error) {
+		if path == "unsafe" {
+			return types.Unsafe, nil
+		}
+		if p, ok := checked[path]; ok {
+			return p, nil
+		}
+		if _, ok := source[path]; ok {
+			if checking[path] {
+				return nil, fmt.Errorf("import cycle via %s", path)
+			}
+			// errors in imported source packages are ignored - they are reported when that
+			// package is checked
+			return check(path, func(error) {})
+		}
+		if p, ok := packages[path]; ok && p.Complete() {
+			return p, nil
+		}
+		a := archive(path)
+		if a == nil {
+			missing[path] = true
+			return nil, fmt.Errorf("%s not loaded - update to download", path)
+		}
+		p, err := gcexportdata.Read(bytes.NewReader(a.ExportData), fset, packages, path)
+		if err != nil {
+			return nil, err
+		}
+		packages[path] = p
+		return p, nil
+	}
+
+	check = func(path string, errorFunc func(error)) (*types.Package, error) {
+		checking[path] = true
+		defer delete(checking, path)
+
+		var files []*ast.File
+		for name, contents := range source[path] {
+			include, err := includeFile(name, contents, tags, false)
+			if err != nil {
+				return nil, err
+			}
+			if !include {
+				continue
+			}
+			f, err := parser.ParseFile(fset, name, contents, parser.AllErrors)
+			if err != nil {
+				if list, ok := err.(scanner.ErrorList); ok {
+					for _, e := range list {
+						errorFunc(e)
+					}
+				} else {
+					errorFunc(err)
+				}
+			}
+			if f != nil {
+				files = append(files, f)
+				for _, spec := range f.Imports {
+					if p, err := strconv.Unquote(spec.Path.Value); err == nil {
+						imports[spec.Path.Pos()] = p
+					}
+				}
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no buildable Go source files in %s", path)
+		}
+		conf := types.Config{
+			Importer: imp,
+			Error:    errorFunc,
+			// GopherJS uses 32 bit sizes
+			Sizes: &types.StdSizes{WordSize: 4, MaxAlign: 8},
+		}
+		p, _ := conf.Check(path, fset, files, nil)
+		checked[path] = p
+		return p, nil
+	}
+
+	var errs []error
+	report := func(err error) {
+		if e, ok := err.(types.Error); ok && missing[imports[e.Pos]] {
+			// the archive hasn't been downloaded yet, which isn't an error in the source
+			return
+		}
+		errs = append(errs, err)
+	}
+	if _, err := check(path, report); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
@@ -0,0 +1,276 @@
+package builderjs
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TestMainPath is the import path of the generated package that runs the tests.
+const TestMainPath = "testmain"
+
+// TestSource returns a copy of source with the tests for package path added. The external tests
+// (package <name>_test) are moved to path + "_test", and a main package that runs all the tests is
+// generated at TestMainPath. The package at path should be built with test = true so the internal
+// tests are included.
+func TestSource(path string, source map[string]map[string]string, tags []string) (map[string]map[string]string, error) {
+	fset := token.NewFileSet()
+
+	var internal, external []*ast.File
+	xtest := map[string]string{}
+	files := map[string]string{}
+	for filename, contents := range source[path] {
+		include, err := includeFile(filename, contents, tags, true)
+		if err != nil {
+			return nil, err
+		}
+		if !include || !strings.HasSuffix(filename, "_test.go") {
+			files[filename] = contents
+			continue
+		}
+		f, err := parser.ParseFile(fset, filename, contents, parser.ParseComments)
+		if err != nil {
+			return nil, &CompileError{Path: path, Errors: []error{err}}
+		}
+		if strings.HasSuffix(f.Name.Name, "_test") {
+			xtest[filename] = contents
+			external = append(external, f)
+		} else {
+			files[filename] = contents
+			internal = append(internal, f)
+		}
+	}
+
+	if len(internal) == 0 && len(external) == 0 {
+		return nil, fmt.Errorf("no test files in %s", path)
+	}
+
+	// Files must be in the same order to get reproducible output
+	sortFiles := func(files []*ast.File) {
+		sort.Slice(files, func(i, j int) bool {
+			return fset.File(files[i].Pos()).Name() < fset.File(files[j].Pos()).Name()
+		})
+	}
+	sortFiles(internal)
+	sortFiles(external)
+
+	data := testMainData{
+		Path:  path,
+		XPath: path + "_test",
+	}
+	imports := map[string]bool{}
+	for _, f := range internal {
+		if err := data.scan(fset, f, "_test", imports); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range external {
+		if err := data.scan(fset, f, "_xtest", imports); err != nil {
+			return nil, err
+		}
+	}
+	data.examples(internal, "_test")
+	data.examples(external, "_xtest")
+	data.External = len(external) > 0
+	for _, f := range data.funcs() {
+		switch f.Package {
+		case "_test":
+			data.NeedTest = true
+		case "_xtest":
+			data.NeedXTest = true
+		}
+	}
+
+	// The generated package imports every dependency of the tests, so a single update request will
+	// fetch all the archives needed to compile them.
+	for imp := range imports {
+		if imp == path || imp == data.XPath || source[imp] != nil || testMainImports[imp] {
+			continue
+		}
+		data.Imports = append(data.Imports, imp)
+	}
+	sort.Strings(data.Imports)
+
+	buf := &bytes.Buffer{}
+	if err := testMainTemplate.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	main, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]map[string]string{}
+	for p, f := range source {
+		out[p] = f
+	}
+	out[path] = files
+	if len(xtest) > 0 {
+		out[data.XPath] = xtest
+	}
+	out[TestMainPath] = map[string]string{"main.go": string(main)}
+
+	return out, nil
+}
+
+// Imports returns the sorted imports of all the files in a package that are included in the build.
+func Imports(files map[string]string, tags []string, test bool) ([]string, error) {
+	fset := token.NewFileSet()
+	m := map[string]bool{}
+	for name, contents := range files {
+		include, err := includeFile(name, contents, tags, test)
+		if err != nil {
+			return nil, err
+		}
+		if !include {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, contents, parser.ImportsOnly)
+		if err != nil {
+			return nil, err
+		}
+		for _, spec := range f.Imports {
+			imp, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[imp] = true
+		}
+	}
+	var imports []string
+	for imp := range m {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	return imports, nil
+}
+
+// testMainImports are imported by the generated test main package
+var testMainImports = map[string]bool{"os": true, "regexp": true, "testing": true}
+
+type testMainData struct {
+	Path, XPath         string
+	External            bool // the package has external tests
+	NeedTest, NeedXTest bool // the tests reference the internal / external test package
+	Imports             []string
+	Tests               []testFunc
+	Benchmarks          []testFunc
+	Examples            []testExample
+}
+
+func (d *testMainData) funcs() []testFunc {
+	funcs := append([]testFunc{}, d.Tests...)
+	funcs = append(funcs, d.Benchmarks...)
+	for _, e := range d.Examples {
+		funcs = append(funcs, testFunc{e.Package, e.Name})
+	}
+	return funcs
+}
+
+type testFunc struct {
+	Package, Name string
+}
+
+type testExample struct {
+	Package, Name, Output string
+	Unordered             bool
+}
+
+func (d *testMainData) scan(fset *token.FileSet, f *ast.File, pkg string, imports map[string]bool) error {
+	for _, spec := range f.Imports {
+		imp, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return err
+		}
+		imports[imp] = true
+	}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil {
+			continue
+		}
+		name := fn.Name.Name
+		switch {
+		case name == "TestMain":
+			return fmt.Errorf("%s: TestMain is not supported", fset.Position(fn.Pos()))
+		case isTest(name, "Test"):
+			d.Tests = append(d.Tests, testFunc{pkg, name})
+		case isTest(name, "Benchmark"):
+			d.Benchmarks = append(d.Benchmarks, testFunc{pkg, name})
+		}
+	}
+	return nil
+}
+
+func (d *testMainData) examples(files []*ast.File, pkg string) {
+	for _, e := range doc.Examples(files...) {
+		if e.Output == "" && !e.EmptyOutput {
+			// examples without output comments are compiled but not run
+			continue
+		}
+		d.Examples = append(d.Examples, testExample{pkg, "Example" + e.Name, e.Output, e.Unordered})
+	}
+}
+
+// isTest tells whether name looks like a test (or benchmark, according to prefix). It is a Test
+// (say) if there is a character after Test that is not a lower-case letter.
+func isTest(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
+
+var testMainTemplate = template.Must(template.New("main").Parse(`package main
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	{{if .NeedTest}}_test{{else}}_{{end}} {{printf "%q" .Path}}
+	{{- if .External}}
+	{{if .NeedXTest}}_xtest{{else}}_{{end}} {{printf "%q" .XPath}}
+	{{- end}}
+	{{- range .Imports}}
+	_ {{printf "%q" .}}
+	{{- end}}
+)
+
+var tests = []testing.InternalTest{
+	{{- range .Tests}}
+	{ {{- printf "%q" .Name}}, {{.Package}}.{{.Name -}} },
+	{{- end}}
+}
+
+var benchmarks = []testing.InternalBenchmark{
+	{{- range .Benchmarks}}
+	{ {{- printf "%q" .Name}}, {{.Package}}.{{.Name -}} },
+	{{- end}}
+}
+
+var examples = []testing.InternalExample{
+	{{- range .Examples}}
+	{ {{- printf "%q" .Name}}, {{.Package}}.{{.Name}}, {{printf "%q" .Output}}, {{.Unordered -}} },
+	{{- end}}
+}
+
+func main() {
+	os.Args = []string{"test", "-test.v"}
+	testing.Main(regexp.MatchString, tests, benchmarks, examples)
+}
+`))
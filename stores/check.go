@@ -0,0 +1,94 @@
+package stores
+
+import (
+	"go/types"
+	"time"
+
+	"github.com/dave/flux"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/stores/builderjs"
+	"github.com/gopherjs/gopherjs/compiler"
+)
+
+// checkDelay is how long to wait after the last change before type-checking
+const checkDelay = time.Millisecond * 750
+
+func NewCheckStore(app *App) *CheckStore {
+	s := &CheckStore{
+		app:      app,
+		packages: map[string]*types.Package{},
+		hashes:   map[string]string{},
+	}
+	return s
+}
+
+// CheckStore type-checks source packages in the background as they are edited, and publishes the
+// errors as diagnostics.
+type CheckStore struct {
+	app *App
+
+	packages map[string]*types.Package // packages loaded from export data
+	hashes   map[string]string         // hashes of the archives used to load packages
+	pending  int                       // number of scheduled checks that aren't due yet
+}
+
+func (s *CheckStore) Handle(payload *flux.Payload) bool {
+	switch a := payload.Action.(type) {
+	case *actions.UserChangedText:
+		payload.Wait(s.app.Source)
+		if a.Changed {
+			s.schedule(s.app.Editor.CurrentPackage())
+		}
+	case *actions.FormatCode, *actions.AddFile, *actions.DeleteFile, *actions.RestoreSource:
+		payload.Wait(s.app.Source)
+		payload.Wait(s.app.Editor)
+		s.schedule(s.app.Editor.CurrentPackage())
+	case *actions.RequestClose:
+		// the archives may have been updated
+		payload.Wait(s.app.Archive)
+		s.schedule(s.app.Editor.CurrentPackage())
+	case *actions.CheckDue:
+		s.pending--
+		if s.pending > 0 || s.app.Compile.Compiling() || !s.app.Source.HasPackage(a.Path) {
+			// schedule was called again in the meantime, or the package will be checked by the
+			// compile
+			return true
+		}
+		s.app.Dispatch(&actions.CheckComplete{Path: a.Path, Errors: s.check(a.Path)})
+	}
+	return true
+}
+
+// schedule type-checks the package after checkDelay, unless schedule is called again in the
+// meantime. The check is made when CheckDue is handled, so it doesn't race with the other stores.
+func (s *CheckStore) schedule(path string) {
+	s.pending++
+	go func() {
+		<-time.After(checkDelay)
+		s.app.Dispatch(&actions.CheckDue{Path: path})
+	}()
+}
+
+func (s *CheckStore) check(path string) []error {
+	cache := s.app.Archive.Cache()
+
+	// the loaded packages refer to each other, so if any archive has changed, start again
+	for p, hash := range s.hashes {
+		if item, ok := cache[p]; !ok || item.Hash != hash {
+			s.packages = map[string]*types.Package{}
+			s.hashes = map[string]string{}
+			break
+		}
+	}
+
+	archive := func(p string) *compiler.Archive {
+		item, ok := cache[p]
+		if !ok || item.Archive == nil {
+			return nil
+		}
+		s.hashes[p] = item.Hash
+		return item.Archive
+	}
+
+	return builderjs.Check(path, s.app.Source.Source(), s.app.Compile.Tags(), archive, s.packages)
+}
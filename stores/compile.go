@@ -3,18 +3,14 @@ package stores
 import (
 	"errors"
 
-	"bytes"
-	"text/template"
-
-	"strconv"
+	"strings"
 
 	"fmt"
 
 	"github.com/dave/flux"
 	"github.com/dave/play/actions"
 	"github.com/dave/play/models"
-	"github.com/gopherjs/gopherjs/js"
-	"honnef.co/go/js/dom"
+	"github.com/dave/play/stores/builderjs"
 )
 
 func NewCompileStore(app *App) *CompileStore {
@@ -25,17 +21,41 @@ func NewCompileStore(app *App) *CompileStore {
 }
 
 type CompileStore struct {
-	app            *App
-	compiling      bool
-	compiled       bool
-	consoleWritten bool
-	tags           []string
+	app       *App
+	compiling bool
+	compiled  bool
+	tags      []string
+	configs   []models.RunConfig
+	selected  string // name of the selected run configuration, or empty to run the current package
+
+	// token identifies the running compile. It's cleared by Stop so the result is discarded.
+	token *struct{}
 }
 
 func (s *CompileStore) Tags() []string {
 	return s.tags
 }
 
+// RunConfigs returns the named run configurations
+func (s *CompileStore) RunConfigs() []models.RunConfig {
+	return s.configs
+}
+
+// Selected is the name of the selected run configuration, or empty if the current package is run
+func (s *CompileStore) Selected() string {
+	return s.selected
+}
+
+// RunConfig returns the selected run configuration. ok is false if the current package is run.
+func (s *CompileStore) RunConfig() (config models.RunConfig, ok bool) {
+	for _, c := range s.configs {
+		if c.Name == s.selected && s.selected != "" {
+			return c, true
+		}
+	}
+	return models.RunConfig{}, false
+}
+
 func (s *CompileStore) Compiling() bool {
 	return s.compiling
 }
@@ -48,23 +68,117 @@ func (s *CompileStore) Handle(payload *flux.Payload) bool {
 	switch a := payload.Action.(type) {
 	case *actions.LoadSource:
 		s.tags = append(s.tags, a.Tags...)
+		if a.Run != nil {
+			s.configs = a.Run
+			s.selected = a.RunConfig
+			s.apply()
+		}
 		payload.Notify()
 	case *actions.CompileStart:
 		if err := s.compile(); err != nil {
-			s.app.Fail(err)
+			s.fail(err)
 			return true
 		}
 		payload.Notify()
+	case *actions.TestStart:
+		if err := s.test(a.Path); err != nil {
+			s.fail(err)
+			return true
+		}
+		payload.Notify()
+	case *actions.CompileComplete:
+		s.compiling = false
+		s.token = nil
+		if a.Err != nil {
+			s.fail(a.Err)
+		} else if err := s.run(a.Path, a.Deps, a.Wasm, a.Index); err != nil {
+			s.app.Fail(models.RuntimeSource, err)
+		}
+		payload.Notify()
+	case *actions.Stop:
+		if s.compiling {
+			s.app.Archive.CancelCompile()
+			s.compiling = false
+			s.token = nil
+		}
+		s.app.Runner.Stop()
+		s.compiled = false
+		s.app.LogHide("stopped")
+		payload.Notify()
 	case *actions.BuildTags:
 		s.tags = a.Tags
+		// the selected run configuration remembers the build tags
+		s.update(func(c *models.RunConfig) { c.Tags = a.Tags })
+		payload.Notify()
+	case *actions.MinifyToggleClick:
+		payload.Wait(s.app.Page)
+		s.update(func(c *models.RunConfig) { c.Minify = s.app.Page.Minify() })
 		payload.Notify()
+	case *actions.RunConfigs:
+		s.configs = a.Configs
+		s.selected = a.Selected
+		s.apply()
+		payload.Notify()
+	case *actions.SelectRunConfig:
+		s.selected = a.Name
+		s.apply()
+		payload.Notify()
+	}
+	return true
+}
+
+// apply changes the build tags and minify setting to those of the selected run configuration
+func (s *CompileStore) apply() {
+	config, ok := s.RunConfig()
+	if !ok {
+		return
+	}
+	if !equal(config.Tags, s.tags) {
+		s.app.Dispatch(&actions.BuildTags{Tags: config.Tags})
+	}
+	if config.Minify != s.app.Page.Minify() {
+		s.app.Dispatch(&actions.MinifyToggleClick{})
+	}
+}
+
+// update changes the selected run configuration
+func (s *CompileStore) update(f func(*models.RunConfig)) {
+	for i := range s.configs {
+		if s.configs[i].Name == s.selected && s.selected != "" {
+			f(&s.configs[i])
+		}
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
 	}
 	return true
 }
 
+// fail reports errors in the source as diagnostics, and all other errors with App.Fail
+func (s *CompileStore) fail(err error) {
+	if ce, ok := err.(*builderjs.CompileError); ok {
+		s.app.Dispatch(&actions.CompileFailed{Path: ce.Path, Errors: ce.Errors})
+		return
+	}
+	s.app.Fail(models.CompileSource, err)
+}
+
 func (s *CompileStore) compile() error {
 	path, count := s.app.Scanner.Main()
-	if path == "" {
+	if config, ok := s.RunConfig(); ok {
+		if !s.app.Scanner.MainPackages()[config.Path] {
+			return fmt.Errorf("%s in run configuration %q is not a main package", config.Path, config.Name)
+		}
+		path = config.Path
+	} else if path == "" {
 		if count == 0 {
 			return errors.New("project has no main package")
 		} else {
@@ -72,6 +186,23 @@ func (s *CompileStore) compile() error {
 		}
 	}
 
+	index := s.app.Source.Files(path)["index.jsgo.html"]
+
+	if config, ok := s.RunConfig(); ok && config.Target == models.WasmTarget {
+		// compiled by the server, so the archives aren't needed
+		s.app.Log("compiling to WebAssembly")
+		request := models.WasmRequest{
+			Path:   path,
+			Source: copySource(s.app.Source.Source()),
+			Tags:   s.Tags(),
+		}
+		s.start(path, index, func(progress func(string)) ([]models.Dep, *models.Wasm, error) {
+			wasm, err := s.app.Backend.Backend().Wasm(request)
+			return nil, wasm, err
+		})
+		return nil
+	}
+
 	if !s.app.Archive.Fresh(path) {
 		s.app.Dispatch(
 			&actions.RequestStart{Type: models.UpdateRequest, Run: true},
@@ -79,109 +210,87 @@ func (s *CompileStore) compile() error {
 		return nil
 	}
 
-	s.compiling = true
-	defer func() {
-		s.compiling = false
-	}()
-
 	s.app.Log("compiling")
 
-	deps, err := s.app.Archive.Compile(path, s.Tags())
-	if err != nil {
-		return err
-	}
+	s.start(path, index, func(progress func(string)) ([]models.Dep, *models.Wasm, error) {
+		deps, err := s.app.Archive.Compile(path, s.Tags(), progress)
+		return deps, nil, err
+	})
+	return nil
+}
 
-	s.app.Log("running")
+func (s *CompileStore) test(path string) error {
+	if path == "" {
+		path = s.app.Editor.CurrentPackage()
+	}
+	if path == "" {
+		return errors.New("no package selected")
+	}
 
-	doc := dom.GetWindow().Document()
-	holder := doc.GetElementByID("iframe-holder")
-	for _, v := range holder.ChildNodes() {
-		v.Underlying().Call("remove")
-	}
-	frame := doc.CreateElement("iframe").(*dom.HTMLIFrameElement)
-	frame.SetID("iframe")
-	frame.Style().Set("width", "100%")
-	frame.Style().Set("height", "100%")
-	frame.Style().Set("border", "0")
-
-	// We need to wait for the iframe to load before adding contents or Firefox will clear the iframe
-	// after momentarily flashing up the contents.
-	c := make(chan struct{})
-	listener := frame.AddEventListener("load", false, func(event dom.Event) {
-		close(c)
-	})
+	if !s.app.Archive.FreshTest(path) {
+		s.app.Dispatch(
+			&actions.RequestStart{Type: models.UpdateRequest, Path: path, Test: true},
+		)
+		return nil
+	}
 
-	holder.AppendChild(frame)
-	<-c
+	s.app.Log("compiling tests")
 
-	// remove the listener so if it's triggered again we don't close the closed channel
-	frame.RemoveEventListener("load", false, listener)
+	s.start(builderjs.TestMainPath, s.app.Source.Files(path)["index.jsgo.html"], func(progress func(string)) ([]models.Dep, *models.Wasm, error) {
+		deps, err := s.app.Archive.CompileTest(path, s.Tags(), progress)
+		return deps, nil, err
+	})
+	return nil
+}
 
-	console := dom.GetWindow().Document().GetElementByID("console")
-	console.SetInnerHTML("")
-	frame.Get("contentWindow").Set("goPrintToConsole", js.InternalObject(func(b []byte) {
-		console.SetInnerHTML(console.InnerHTML() + string(b))
-		if !s.consoleWritten {
-			s.consoleWritten = true
-			s.app.Dispatch(&actions.ConsoleFirstWrite{})
+// start runs compile in the background, so the page stays responsive. CompileComplete is
+// dispatched when it finishes.
+func (s *CompileStore) start(path, index string, compile func(progress func(string)) ([]models.Dep, *models.Wasm, error)) {
+	s.compiling = true
+	token := &struct{}{}
+	s.token = token
+	go func() {
+		deps, wasm, err := compile(func(p string) {
+			if token == s.token {
+				s.app.Logf("compiling %s", p)
+			}
+		})
+		if token != s.token {
+			// stopped
+			return
 		}
-	}))
+		s.app.Dispatch(&actions.CompileComplete{Path: path, Index: index, Deps: deps, Wasm: wasm, Err: err})
+	}()
+}
 
-	frameDoc := frame.ContentDocument()
+// run runs the main package path, which is either compiled to JS (deps) or WebAssembly (wasm). If
+// index is not empty, it is used as a template for the page.
+func (s *CompileStore) run(path string, deps []models.Dep, wasm *models.Wasm, index string) error {
+	s.app.Log("running")
 
-	if index, ok := s.app.Source.Files(path)["index.jsgo.html"]; ok {
-		// has index
+	s.app.Filesystem.start(path)
 
-		indexTemplate, err := template.New("index").Parse(index)
-		if err != nil {
-			return err
-		}
-		data := struct{ Script string }{Script: ""}
-		buf := &bytes.Buffer{}
-		if err := indexTemplate.Execute(buf, data); err != nil {
-			return err
+	// os.Args and the environment
+	config, ok := s.RunConfig()
+	if !ok || config.Path != path {
+		config = models.RunConfig{}
+	}
+	env := map[string]string{"PWD": "/" + path}
+	for _, kv := range config.Env {
+		if i := strings.Index(kv, "="); i > -1 {
+			env[kv[:i]] = kv[i+1:]
 		}
+	}
 
-		frameDoc.Underlying().Call("open")
-		frameDoc.Underlying().Call("write", buf.String())
-		frameDoc.Underlying().Call("close")
-	}
-
-	head := frameDoc.GetElementsByTagName("head")[0].(*dom.BasicHTMLElement)
-
-	loaderJs := ""
-	for _, dep := range deps {
-		loaderJs += "$load[" + strconv.Quote(dep.Path) + "]();\n"
-	}
-	scriptLoad := frameDoc.CreateElement("script")
-	scriptLoad.SetID("loader")
-	scriptLoad.SetInnerHTML(`
-		var $load = {};
-		var $count = 0;
-		var $total = ` + fmt.Sprint(len(deps)) + `;
-		var $finished = function() {
-			` + loaderJs + `
-			$mainPkg = $packages[` + strconv.Quote(path) + `];
-			$synthesizeMethods();
-			$packages["runtime"].$init();
-			$go($mainPkg.$init, []);
-			$flushConsole();
-		};
-		var $done = function() {
-			$count++;
-			if ($count == $total) {
-				$finished();
-			}
-		};
-	`)
-	head.AppendChild(scriptLoad)
-
-	for _, dep := range deps {
-		scriptDep := frameDoc.CreateElement("script")
-		scriptDep.SetID(dep.Path)
-		scriptDep.SetInnerHTML(string(dep.Js) + "$done();")
-		//scriptDep.AppendChild(doc.CreateTextNode(string(dep.Js) + "$done();"))
-		head.AppendChild(scriptDep)
+	if err := s.app.Runner.Run(Program{
+		Path:  path,
+		Index: index,
+		Deps:  deps,
+		Wasm:  wasm,
+		Args:  append([]string{path}, config.Args...),
+		Env:   env,
+	}); err != nil {
+		return err
 	}
 
 	s.compiled = true
@@ -3,114 +3,252 @@ package stores
 import (
 	"errors"
 
-	"honnef.co/go/js/dom"
-
 	"fmt"
-
-	"strings"
+	"sort"
+	"time"
 
 	"github.com/dave/flux"
-	"github.com/dave/jsgo/server/play/messages"
 	"github.com/dave/jsgo/server/servermsg"
 	"github.com/dave/play/actions"
-	"github.com/gopherjs/gopherjs/js"
-	"github.com/gopherjs/websocket/websocketjs"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores/backend"
+	"github.com/dave/services"
 )
 
 type ConnectionStore struct {
 	app *App
 
-	open bool
-	ws   *websocketjs.WebSocket
+	conn backend.Conn
+
+	// stopped is set when the connection is lost or closed, so the events from it are ignored. Each
+	// connection has its own flag.
+	stopped *bool
+
+	// operations are the operations in progress by ID. They are opened when the connection opens,
+	// and if the connection is lost they are opened again when it's dialed again (up to maxRetries
+	// times with backoff).
+	operations map[int]*actions.Dial
+	last       int // ID of the last operation
+	attempt    int
+	state      models.ConnectionState
 }
 
 func NewConnectionStore(app *App) *ConnectionStore {
 	s := &ConnectionStore{
-		app: app,
+		app:        app,
+		operations: map[int]*actions.Dial{},
 	}
 	return s
 }
 
-func (s *ConnectionStore) Open() bool {
-	return s.open
+// Busy is true while an operation is in progress, including while the connection is being retried
+func (s *ConnectionStore) Busy() bool {
+	return len(s.operations) > 0
+}
+
+// Stoppable is true while an operation that's cancelled by Stop is in progress
+func (s *ConnectionStore) Stoppable() bool {
+	for _, op := range s.operations {
+		if op.Stoppable {
+			return true
+		}
+	}
+	return false
+}
+
+// State is the state of the connection
+func (s *ConnectionStore) State() models.ConnectionState {
+	return s.state
+}
+
+// Attempt is the number of the retry, while the connection is being retried
+func (s *ConnectionStore) Attempt() int {
+	return s.attempt
 }
 
 func (s *ConnectionStore) Handle(payload *flux.Payload) bool {
 	switch action := payload.Action.(type) {
 	case *actions.Send:
-		s.app.Debug(fmt.Sprintf("Sending %T", action.Message), action.Message)
-		if !s.open {
-			s.app.Fail(errors.New("connection closed"))
+		s.app.Debug(fmt.Sprintf("Sending %T", action.Message), action.ID, action.Message)
+		if s.operations[action.ID] == nil {
+			// stopped
 			return true
 		}
-		b, _, err := messages.Marshal(action.Message)
-		if err != nil {
-			s.app.Fail(err)
+		if s.state != models.ConnectionOpen {
+			s.app.Fail(models.NetworkSource, errors.New("connection closed"))
 			return true
 		}
-		if err := s.ws.Send(string(b)); err != nil {
-			s.app.Fail(err)
+		if err := s.conn.Send(action.ID, action.Message); err != nil {
+			s.app.Fail(models.NetworkSource, err)
 			return true
 		}
 	case *actions.Dial:
-		if s.open {
-			s.app.Fail(errors.New("connection already open"))
+		s.last++
+		s.operations[s.last] = action
+		switch s.state {
+		case models.ConnectionOpen:
+			s.app.Dispatch(action.Open(s.last))
+		case models.ConnectionIdle, models.ConnectionFailed:
+			s.attempt = 0
+			s.dial()
+		}
+		// while connecting or retrying, the operation is opened when the connection opens
+		payload.Notify()
+	case *actions.Redial:
+		if len(s.operations) == 0 {
+			// stopped while waiting
+			s.state = models.ConnectionIdle
+			payload.Notify()
 			return true
 		}
-		s.app.Debug("Web socket dialing", action.Url)
-		var err error
-		if s.ws, err = websocketjs.New(action.Url); err != nil {
-			s.app.Fail(err)
+		s.dial()
+		payload.Notify()
+	case *actions.ConnectionOpened:
+		s.state = models.ConnectionOpen
+		for _, id := range s.ids() {
+			s.app.Dispatch(s.operations[id].Open(id))
+		}
+		payload.Notify()
+	case *actions.ConnectionMessage:
+		op, ok := s.operations[action.ID]
+		if !ok {
+			// stopped
 			return true
 		}
-		s.open = true
-		s.ws.AddEventListener("open", false, func(ev *js.Object) {
-			go func() {
-				s.app.Debug("Web socket open")
-				s.app.Dispatch(action.Open())
-			}()
-		})
-		s.ws.AddEventListener("message", false, func(ev *js.Object) {
-			go func() {
-				m, err := messages.Unmarshal([]byte(ev.Get("data").String()))
-				if err != nil {
-					s.app.Fail(err)
-					return
-				}
-				s.app.Debug(fmt.Sprintf("Received %T", m), m)
-				if e, ok := m.(servermsg.Error); ok {
-					s.app.Fail(errors.New(e.Message))
-					return
-				}
-				s.app.Dispatch(action.Message(m))
-			}()
-		})
-		s.ws.AddEventListener("close", false, func(ev *js.Object) {
-			go func() {
-				s.app.Debug("Web socket closed")
-				s.app.Dispatch(action.Close())
-				s.ws.Close()
-				s.open = false
-			}()
-		})
-		s.ws.AddEventListener("error", false, func(ev *js.Object) {
-			go func() {
-				s.app.Debug("Web socket error")
-				s.app.Fail(errors.New("error from server"))
-				s.ws.Close()
-				s.open = false
-			}()
-		})
+		s.app.Dispatch(op.Message(action.Message))
+	case *actions.ConnectionDone:
+		op, ok := s.operations[action.ID]
+		if !ok {
+			// stopped
+			return true
+		}
+		delete(s.operations, action.ID)
+		s.attempt = 0
+		s.app.Dispatch(op.Close())
+		payload.Notify()
+	case *actions.ConnectionClosed:
+		s.conn = nil
+		if len(s.operations) > 0 {
+			s.lost(errors.New("connection closed by server"))
+		} else {
+			s.state = models.ConnectionIdle
+		}
+		payload.Notify()
+	case *actions.ConnectionLost:
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+		s.lost(action.Err)
+		payload.Notify()
+	case *actions.Stop:
+		var stopped bool
+		for id, op := range s.operations {
+			if op.Stoppable {
+				s.app.Debug("Operation stopped", id)
+				delete(s.operations, id)
+				stopped = true
+			}
+		}
+		// the responses for the stopped operations are ignored, and the connection stays open for
+		// the others
+		if stopped {
+			payload.Notify()
+		}
 	}
 	return true
 }
 
-func defaultUrl() string {
-	var url string
-	if strings.HasPrefix(dom.GetWindow().Document().DocumentURI(), "https://") {
-		url = "wss://compile.jsgo.io/_play/"
-	} else {
-		url = "ws://localhost:8081/_play/"
+// ids returns the IDs of the operations in the order they were started
+func (s *ConnectionStore) ids() []int {
+	var ids []int
+	for id := range s.operations {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// lost retries the connection after it's lost. If it has been retried maxRetries times, the
+// operations are closed.
+func (s *ConnectionStore) lost(err error) {
+	if len(s.operations) == 0 {
+		s.state = models.ConnectionIdle
+		return
+	}
+	if s.attempt >= maxRetries {
+		s.app.Fail(models.NetworkSource, err)
+		s.state = models.ConnectionFailed
+		for _, id := range s.ids() {
+			s.app.Dispatch(s.operations[id].Close())
+			delete(s.operations, id)
+		}
+		return
+	}
+	s.attempt++
+	s.state = models.ConnectionRetrying
+	delay := backoff(s.attempt)
+	s.app.Logf("connection lost, retrying in %v", delay)
+	go func() {
+		<-time.After(delay)
+		s.app.Dispatch(&actions.Redial{})
+	}()
+}
+
+// dial opens the connection. The events from the connection are dispatched as actions, so the state
+// is only changed by Handle.
+func (s *ConnectionStore) dial() {
+	s.app.Debug("Web socket dialing")
+	s.state = models.ConnectionConnecting
+	stopped := new(bool)
+	s.stopped = stopped
+	conn, err := s.app.Backend.Backend().Dial(backend.Handler{
+		Open: func() {
+			if *stopped {
+				return
+			}
+			s.app.Debug("Web socket open")
+			s.app.Dispatch(&actions.ConnectionOpened{})
+		},
+		Message: func(id int, m services.Message) {
+			if *stopped {
+				return
+			}
+			s.app.Debug(fmt.Sprintf("Received %T", m), id, m)
+			if e, ok := m.(servermsg.Error); ok {
+				s.app.Fail(models.NetworkSource, errors.New(e.Message))
+				return
+			}
+			s.app.Dispatch(&actions.ConnectionMessage{ID: id, Message: m})
+		},
+		Done: func(id int) {
+			if *stopped {
+				return
+			}
+			s.app.Dispatch(&actions.ConnectionDone{ID: id})
+		},
+		Close: func() {
+			if *stopped {
+				return
+			}
+			s.app.Debug("Web socket closed")
+			*stopped = true
+			s.app.Dispatch(&actions.ConnectionClosed{})
+		},
+		Error: func(err error) {
+			if *stopped {
+				return
+			}
+			s.app.Debug("Web socket error")
+			// the close that follows the error is ignored
+			*stopped = true
+			s.app.Dispatch(&actions.ConnectionLost{Err: err})
+		},
+	})
+	if err != nil {
+		*stopped = true
+		s.app.Dispatch(&actions.ConnectionLost{Err: err})
+		return
 	}
-	return url
+	s.conn = conn
 }
@@ -0,0 +1,256 @@
+package stores
+
+import (
+	"errors"
+	"html"
+	"strings"
+
+	"github.com/dave/flux"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// Output streams of the running program
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+	Stdin  = "stdin" // input is echoed to the console
+)
+
+// consoleMaxLines is the size of the scrollback. Older lines are removed.
+const consoleMaxLines = 5000
+
+func NewConsoleStore(app *App) *ConsoleStore {
+	s := &ConsoleStore{
+		app:    app,
+		styles: map[string]ansiStyle{},
+	}
+	return s
+}
+
+// ConsoleStore writes the output of the running program to the console. The output is written to
+// the DOM as it arrives, rather than rendered, so large amounts of output are fast.
+type ConsoleStore struct {
+	app *App
+
+	lines []*consoleLine
+	open  *consoleLine // open is the line being written, or nil if the last line has ended
+
+	styles  map[string]ansiStyle // stream -> current ANSI style
+	query   string
+	maps    *sourceMaps
+	written bool
+
+	stdin   []byte         // input that hasn't been read by the program
+	closed  bool           // no more input will be sent
+	waiting []*consoleRead // reads that are waiting for input
+	warned  bool           // the user has been told the program read from stdin with no input
+}
+
+// consoleRead is a read from stdin that's waiting for input. callback is called with the number of
+// bytes read into data.
+type consoleRead struct {
+	data     *js.Object
+	callback func(n int)
+}
+
+type consoleLine struct {
+	elem dom.Element
+	text string
+}
+
+// Query is the current search. Only the lines that contain it are shown.
+func (s *ConsoleStore) Query() string {
+	return s.query
+}
+
+// Text returns all the output in the console
+func (s *ConsoleStore) Text() string {
+	var lines []string
+	for _, l := range s.lines {
+		lines = append(lines, l.text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *ConsoleStore) Handle(payload *flux.Payload) bool {
+	switch a := payload.Action.(type) {
+	case *actions.ConsoleClear:
+		s.clear()
+		payload.Notify()
+	case *actions.ConsoleSearch:
+		s.query = a.Query
+		for _, l := range s.lines {
+			s.filter(l)
+		}
+		payload.Notify()
+	case *actions.StdinSend:
+		s.stdin = append(s.stdin, a.Text+"\n"...)
+		s.closed = false
+		s.write(Stdin, a.Text+"\n")
+		s.flush()
+	case *actions.StdinClose:
+		s.closed = true
+		s.flush()
+	case *actions.Stop:
+		s.reset()
+	case *actions.ConsoleCopy:
+		clipboard := js.Global.Get("navigator").Get("clipboard")
+		if clipboard == js.Undefined {
+			s.app.LogHide("copy not supported")
+			return true
+		}
+		clipboard.Call("writeText", s.Text())
+		s.app.LogHide("copied")
+	}
+	return true
+}
+
+// start clears the console for a new run. maps is used to link stack traces to the source.
+func (s *ConsoleStore) start(maps *sourceMaps) {
+	s.clear()
+	s.maps = maps
+	s.reset()
+}
+
+// reset discards the input and the waiting reads of the previous run
+func (s *ConsoleStore) reset() {
+	s.stdin = nil
+	s.closed = false
+	s.waiting = nil
+	s.warned = false
+}
+
+func (s *ConsoleStore) clear() {
+	s.element().SetInnerHTML("")
+	s.lines = nil
+	s.open = nil
+	s.styles = map[string]ansiStyle{}
+}
+
+func (s *ConsoleStore) element() dom.Element {
+	return dom.GetWindow().Document().GetElementByID("console")
+}
+
+// write writes output from the program. This is called from JS, so mustn't block.
+func (s *ConsoleStore) write(stream, text string) {
+	holder := dom.GetWindow().Document().GetElementByID("console-holder")
+	atBottom := holder.Underlying().Get("scrollTop").Int()+holder.Underlying().Get("clientHeight").Int() >= holder.Underlying().Get("scrollHeight").Int()-5
+
+	for i, part := range strings.Split(text, "\n") {
+		if i > 0 {
+			// the previous line has ended
+			if s.open == nil {
+				s.newLine()
+			}
+			s.open = nil
+		}
+		if part == "" {
+			continue
+		}
+		if s.open == nil {
+			s.newLine()
+		}
+		s.append(stream, part)
+	}
+
+	if atBottom {
+		holder.Underlying().Set("scrollTop", holder.Underlying().Get("scrollHeight"))
+	}
+
+	if !s.written {
+		s.written = true
+		s.app.Dispatch(&actions.ConsoleFirstWrite{})
+	}
+}
+
+// read reads stdin into the Uint8Array data, and returns the number of bytes read. GopherJS system
+// calls are synchronous, so reads from stdin wait for input (see wait) only when the standard library
+// was compiled by playserver, which replaces syscall.Read. Otherwise this is called by the syscall
+// shim, and a read with no input queued returns EOF. This is called from JS, so mustn't block.
+func (s *ConsoleStore) read(data *js.Object) int {
+	if len(s.stdin) == 0 && !s.closed && !s.warned {
+		s.warned = true
+		s.app.Warn(models.RuntimeSource, errors.New("the program read from stdin with no input, so it got EOF: the standard library from this server can't wait for input (use playserver or the WebAssembly target)"))
+	}
+	return s.take(data)
+}
+
+// wait reads stdin into the Uint8Array data, and calls callback with the number of bytes read. If
+// there's no input queued, the read waits until a line is entered or the input is closed. This is
+// called from JS (by the wasm shim, or syscall.Read in programs compiled by playserver), so mustn't
+// block.
+func (s *ConsoleStore) wait(data *js.Object, callback func(n int)) {
+	s.waiting = append(s.waiting, &consoleRead{data: data, callback: callback})
+	s.flush()
+}
+
+// flush completes the waiting reads while there's input to read or the input is closed
+func (s *ConsoleStore) flush() {
+	for len(s.waiting) > 0 && (len(s.stdin) > 0 || s.closed) {
+		r := s.waiting[0]
+		s.waiting = s.waiting[1:]
+		r.callback(s.take(r.data))
+	}
+}
+
+// take moves up to the length of data bytes of the queued input into data
+func (s *ConsoleStore) take(data *js.Object) int {
+	n := data.Length()
+	if n > len(s.stdin) {
+		n = len(s.stdin)
+	}
+	for i := 0; i < n; i++ {
+		data.SetIndex(i, s.stdin[i])
+	}
+	s.stdin = s.stdin[n:]
+	return n
+}
+
+func (s *ConsoleStore) newLine() {
+	l := &consoleLine{elem: dom.GetWindow().Document().CreateElement("div")}
+	l.elem.Class().Add("console-line")
+	s.element().AppendChild(l.elem)
+	s.lines = append(s.lines, l)
+	s.open = l
+	if len(s.lines) > consoleMaxLines {
+		s.element().RemoveChild(s.lines[0].elem)
+		s.lines = s.lines[1:]
+	}
+	s.filter(l)
+}
+
+func (s *ConsoleStore) append(stream, text string) {
+	style, ok := s.styles[stream]
+	if !ok {
+		style = defaultStyle
+	}
+	var runs []ansiRun
+	runs, s.styles[stream] = parseANSI(text, style)
+	for _, run := range runs {
+		span := dom.GetWindow().Document().CreateElement("span")
+		span.Class().Add(stream)
+		for _, c := range run.style.classes() {
+			span.Class().Add(c)
+		}
+		if s.maps != nil {
+			span.SetInnerHTML(s.maps.HTML(run.text))
+		} else {
+			span.SetInnerHTML(html.EscapeString(run.text))
+		}
+		s.open.elem.AppendChild(span)
+		s.open.text += run.text
+	}
+	s.filter(s.open)
+}
+
+// filter hides the line if it doesn't match the search
+func (s *ConsoleStore) filter(l *consoleLine) {
+	display := ""
+	if s.query != "" && !strings.Contains(strings.ToLower(l.text), strings.ToLower(s.query)) {
+		display = "none"
+	}
+	l.elem.(dom.HTMLElement).Style().Set("display", display)
+}
@@ -27,11 +27,11 @@ type DeployStore struct {
 }
 
 func (s *DeployStore) LoaderJs() string {
-	return fmt.Sprintf("%s://%s/%s.%s.js", config.Protocol[config.Pkg], config.Host[config.Pkg], s.mainPath, s.mainHash)
+	return s.app.Backend.Backend().URL(config.Pkg, fmt.Sprintf("%s.%s.js", s.mainPath, s.mainHash))
 }
 
 func (s *DeployStore) Index() string {
-	return fmt.Sprintf("%s://%s/%s", config.Protocol[config.Index], config.Host[config.Index], s.indexHash)
+	return s.app.Backend.Backend().URL(config.Index, s.indexHash)
 }
 
 func (s *DeployStore) Handle(payload *flux.Payload) bool {
@@ -40,10 +40,10 @@ func (s *DeployStore) Handle(payload *flux.Payload) bool {
 		path, count := s.app.Scanner.Main()
 		if path == "" {
 			if count == 0 {
-				s.app.Fail(errors.New("project has no main package"))
+				s.app.Fail(models.ProjectSource, errors.New("project has no main package"))
 				return true
 			} else {
-				s.app.Fail(fmt.Errorf("project has %d main packages - select one and retry", count))
+				s.app.Fail(models.ProjectSource, fmt.Errorf("project has %d main packages - select one and retry", count))
 				return true
 			}
 		}
@@ -52,8 +52,7 @@ func (s *DeployStore) Handle(payload *flux.Payload) bool {
 		s.indexHash = ""
 		s.mainPath = path
 		s.app.Dispatch(&actions.Dial{
-			Url:     defaultUrl(),
-			Open:    func() flux.ActionInterface { return &actions.DeployOpen{} },
+			Open:    func(id int) flux.ActionInterface { return &actions.DeployOpen{ID: id} },
 			Message: func(m interface{}) flux.ActionInterface { return &actions.DeployMessage{Message: m} },
 			Close:   func() flux.ActionInterface { return &actions.DeployClose{} },
 		})
@@ -66,6 +65,7 @@ func (s *DeployStore) Handle(payload *flux.Payload) bool {
 			Tags:    s.app.Compile.Tags(),
 		}
 		s.app.Dispatch(&actions.Send{
+			ID:      action.ID,
 			Message: message,
 		})
 	case *actions.DeployMessage:
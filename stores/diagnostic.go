@@ -0,0 +1,152 @@
+package stores
+
+import (
+	"go/scanner"
+	"go/types"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dave/flux"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+)
+
+func NewDiagnosticStore(app *App) *DiagnosticStore {
+	s := &DiagnosticStore{
+		app: app,
+	}
+	return s
+}
+
+type DiagnosticStore struct {
+	app *App
+
+	diagnostics []models.Diagnostic
+}
+
+// Diagnostics returns all diagnostics ordered by package, file and position
+func (s *DiagnosticStore) Diagnostics() []models.Diagnostic {
+	return s.diagnostics
+}
+
+// File returns the diagnostics for a single file
+func (s *DiagnosticStore) File(path, name string) []models.Diagnostic {
+	var d []models.Diagnostic
+	for _, v := range s.diagnostics {
+		if v.Path == path && v.File == name {
+			d = append(d, v)
+		}
+	}
+	return d
+}
+
+func (s *DiagnosticStore) Handle(payload *flux.Payload) bool {
+	switch a := payload.Action.(type) {
+	case *actions.CompileStart, *actions.TestStart, *actions.ClearDiagnostics:
+		if len(s.diagnostics) > 0 {
+			s.diagnostics = nil
+			payload.Notify()
+		}
+	case *actions.RemovePackage:
+		s.remove(func(d models.Diagnostic) bool { return d.Path == a.Path })
+		payload.Notify()
+	case *actions.DeleteFile:
+		path := s.app.Editor.CurrentPackage()
+		s.remove(func(d models.Diagnostic) bool { return d.Path == path && d.File == a.Name })
+		payload.Notify()
+	case *actions.RestoreSource:
+		payload.Wait(s.app.Source)
+		s.remove(func(d models.Diagnostic) bool { return !s.app.Source.HasFile(d.Path, d.File) })
+		payload.Notify()
+	case *actions.CheckComplete:
+		// replace the diagnostics for the checked package
+		s.remove(func(d models.Diagnostic) bool { return d.Path == a.Path })
+		for _, err := range a.Errors {
+			s.diagnostics = append(s.diagnostics, s.parse(a.Path, err)...)
+		}
+		s.sortDiagnostics()
+		payload.Notify()
+	case *actions.CompileFailed:
+		var diagnostics []models.Diagnostic
+		for _, err := range a.Errors {
+			diagnostics = append(diagnostics, s.parse(a.Path, err)...)
+		}
+		s.diagnostics = diagnostics
+		s.sortDiagnostics()
+		if len(diagnostics) == 1 {
+			s.app.Log("1 error")
+		} else {
+			s.app.Logf("%d errors", len(diagnostics))
+		}
+		payload.Notify()
+	}
+	return true
+}
+
+func (s *DiagnosticStore) sortDiagnostics() {
+	sort.SliceStable(s.diagnostics, func(i, j int) bool {
+		a, b := s.diagnostics[i], s.diagnostics[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+func (s *DiagnosticStore) remove(f func(models.Diagnostic) bool) {
+	var diagnostics []models.Diagnostic
+	for _, d := range s.diagnostics {
+		if !f(d) {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	s.diagnostics = diagnostics
+}
+
+// parse converts an error returned by the compiler to diagnostics
+func (s *DiagnosticStore) parse(path string, err error) []models.Diagnostic {
+	switch err := err.(type) {
+	case scanner.ErrorList:
+		var d []models.Diagnostic
+		for _, e := range err {
+			d = append(d, s.diagnostic(path, e.Pos.Filename, e.Pos.Line, e.Pos.Column, e.Msg))
+		}
+		return d
+	case *scanner.Error:
+		return []models.Diagnostic{s.diagnostic(path, err.Pos.Filename, err.Pos.Line, err.Pos.Column, err.Msg)}
+	case types.Error:
+		pos := err.Fset.Position(err.Pos)
+		return []models.Diagnostic{s.diagnostic(path, pos.Filename, pos.Line, pos.Column, err.Msg)}
+	}
+	// fall back to parsing the error string
+	if matches := errorRegex.FindStringSubmatch(err.Error()); matches != nil {
+		line, _ := strconv.Atoi(matches[2])
+		column, _ := strconv.Atoi(matches[3])
+		return []models.Diagnostic{s.diagnostic(path, matches[1], line, column, matches[4])}
+	}
+	return []models.Diagnostic{{Path: path, Message: err.Error()}}
+}
+
+func (s *DiagnosticStore) diagnostic(path, file string, line, column int, message string) models.Diagnostic {
+	if !s.app.Source.HasFile(path, file) && s.app.Source.HasFile(strings.TrimSuffix(path, "_test"), file) {
+		// external test files are compiled as path + "_test", but live in path
+		path = strings.TrimSuffix(path, "_test")
+	}
+	return models.Diagnostic{
+		Path:    path,
+		File:    file,
+		Line:    line,
+		Column:  column,
+		Message: message,
+	}
+}
+
+var errorRegex = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?: (.*)$`)
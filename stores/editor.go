@@ -3,6 +3,7 @@ package stores
 import (
 	"github.com/dave/flux"
 	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
 )
 
 func NewEditorStore(app *App) *EditorStore {
@@ -20,12 +21,19 @@ type EditorStore struct {
 	currentPackage string
 	currentFiles   map[string]string // tracks the currently selected file in each package
 	loaded         bool
+	cursor         *models.Position // position in the current file to move the cursor to
 }
 
 func (s *EditorStore) Loaded() bool {
 	return s.loaded
 }
 
+// Cursor is the position in the current file the editor should move the cursor to. A new value is
+// created each time, so the editor can tell when it changes.
+func (s *EditorStore) Cursor() *models.Position {
+	return s.cursor
+}
+
 func (s *EditorStore) Sizes() []float64 {
 	return s.sizes
 }
@@ -123,9 +131,23 @@ func (s *EditorStore) Handle(payload *flux.Payload) bool {
 	case *actions.UserChangedFile:
 		s.currentFiles[s.currentPackage] = a.Name
 		payload.Notify()
+	case *actions.RestoreSource:
+		payload.Wait(s.app.Scanner)
+		s.currentPackage = a.CurrentPackage
+		if !s.app.Source.HasPackage(s.currentPackage) {
+			s.currentPackage = s.defaultPackage()
+		}
+		s.currentFiles[s.currentPackage] = a.CurrentFile
+		if !s.app.Source.HasFile(s.currentPackage, a.CurrentFile) {
+			s.currentFiles[s.currentPackage] = s.defaultFile(s.currentPackage)
+		}
+		payload.Notify()
 	case *actions.ChangeFile:
 		s.currentPackage = a.Path
 		s.currentFiles[a.Path] = a.Name
+		if a.Line > 0 {
+			s.cursor = &models.Position{Line: a.Line, Column: a.Column}
+		}
 		payload.Notify()
 	case *actions.UserChangedPackage:
 		s.currentPackage = a.Path
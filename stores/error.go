@@ -0,0 +1,116 @@
+package stores
+
+import (
+	"time"
+
+	"github.com/dave/flux"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+)
+
+// errorLogSize is the number of errors kept in the log. Older errors are removed.
+const errorLogSize = 100
+
+func NewErrorStore(app *App) *ErrorStore {
+	s := &ErrorStore{
+		app: app,
+	}
+	return s
+}
+
+// ErrorStore keeps the errors reported by App.Fail and App.Warn. The errors that haven't been
+// dismissed are shown as notifications, and the log (persisted in local storage at "errors") is
+// shown in the errors modal.
+type ErrorStore struct {
+	app *App
+
+	log  []models.Error // oldest first
+	last int            // ID of the last error
+}
+
+// Log returns the errors, oldest first
+func (s *ErrorStore) Log() []models.Error {
+	return s.log
+}
+
+// Notifications returns the errors that haven't been dismissed, oldest first
+func (s *ErrorStore) Notifications() []models.Error {
+	var errors []models.Error
+	for _, e := range s.log {
+		if !e.Dismissed {
+			errors = append(errors, e)
+		}
+	}
+	return errors
+}
+
+func (s *ErrorStore) Handle(payload *flux.Payload) bool {
+	switch a := payload.Action.(type) {
+	case *actions.Load:
+		var log []models.Error
+		if _, err := s.app.Storage.Find("errors", &log); err != nil {
+			s.app.Debug("Error log not loaded:", err.Error())
+			return true
+		}
+		// the errors from previous page loads are only shown in the log
+		for i := range log {
+			s.last++
+			log[i].ID = s.last
+			log[i].Dismissed = true
+		}
+		s.log = append(log, s.log...)
+		payload.Notify()
+	case *actions.ReportError:
+		s.report(a.Source, a.Severity, a.Message)
+		s.save()
+		payload.Notify()
+	case *actions.DismissError:
+		for i := range s.log {
+			if a.ID == 0 || s.log[i].ID == a.ID {
+				s.log[i].Dismissed = true
+			}
+		}
+		s.save()
+		payload.Notify()
+	case *actions.ClearErrors:
+		s.log = nil
+		s.save()
+		payload.Notify()
+	}
+	return true
+}
+
+// report adds an error to the log. If the same error has been reported before it's moved to the
+// end of the log and shown again.
+func (s *ErrorStore) report(source models.ErrorSource, severity models.Severity, message string) {
+	e := models.Error{
+		Source:   source,
+		Severity: severity,
+		Message:  message,
+	}
+	for i, previous := range s.log {
+		if previous.Source == source && previous.Severity == severity && previous.Message == message {
+			e = previous
+			s.log = append(s.log[:i], s.log[i+1:]...)
+			break
+		}
+	}
+	if e.ID == 0 {
+		s.last++
+		e.ID = s.last
+	}
+	e.Count++
+	e.Time = time.Now()
+	e.Dismissed = false
+	s.log = append(s.log, e)
+	if len(s.log) > errorLogSize {
+		s.log = s.log[len(s.log)-errorLogSize:]
+	}
+}
+
+func (s *ErrorStore) save() {
+	if err := s.app.Storage.Save("errors", s.log); err != nil {
+		// reporting this would save again
+		s.app.Debug("Error log not saved:", err.Error())
+	}
+}
@@ -0,0 +1,59 @@
+package stores
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+)
+
+func TestErrorsCombined(t *testing.T) {
+	app := newTestApp(nil)
+
+	app.Fail(models.NetworkSource, errors.New("connection closed"))
+	app.Fail(models.StorageSource, errors.New("quota exceeded"))
+	app.Fail(models.NetworkSource, errors.New("connection closed"))
+	waitFor(t, app, "3 reports", func() bool {
+		log := app.Error.Log()
+		return len(log) == 2 && log[1].Count == 2
+	})
+
+	// the repeated error is moved to the end of the log
+	log := errorLog(app)
+	if log[0].Message != "quota exceeded" || log[1].Message != "connection closed" {
+		t.Fatalf("unexpected log %v", log)
+	}
+
+	<-app.Dispatch(&actions.DismissError{ID: log[1].ID})
+	if n := notifications(app); len(n) != 1 || n[0].Message != "quota exceeded" {
+		t.Fatalf("unexpected notifications %v", n)
+	}
+
+	// a dismissed error is shown again when it's reported again, and the log is persisted
+	app.Fail(models.NetworkSource, errors.New("connection closed"))
+	waitFor(t, app, "saved log", func() bool {
+		var saved []models.Error
+		if _, err := app.Storage.Find("errors", &saved); err != nil {
+			// this runs in the dispatcher, so it can't stop the test
+			t.Error(err)
+			return false
+		}
+		return len(saved) == 2 && saved[1].Count == 3 && !saved[1].Dismissed
+	})
+	if n := notifications(app); len(n) != 2 {
+		t.Fatalf("unexpected notifications %v", n)
+	}
+
+	<-app.Dispatch(&actions.ClearErrors{})
+	if len(errorLog(app)) > 0 || len(notifications(app)) > 0 {
+		t.Fatal("log not cleared")
+	}
+}
+
+// notifications returns the errors that haven't been dismissed
+func notifications(app *App) []models.Error {
+	var n []models.Error
+	read(app, func() { n = app.Error.Notifications() })
+	return n
+}
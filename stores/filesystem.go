@@ -0,0 +1,706 @@
+package stores
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dave/flux"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/gopherjs/gopherjs/compiler"
+	"github.com/gopherjs/gopherjs/js"
+	"golang.org/x/tools/go/gcexportdata"
+)
+
+// System calls used by the os package. The standard library is compiled for linux, but with the
+// architecture of the server, so the numbers of the target are translated to these linux/amd64
+// numbers (see fsTarget).
+const (
+	sysRead       = 0
+	sysWrite      = 1
+	sysOpen       = 2
+	sysClose      = 3
+	sysStat       = 4
+	sysFstat      = 5
+	sysLstat      = 6
+	sysLseek      = 8
+	sysPread      = 17
+	sysPwrite     = 18
+	sysAccess     = 21
+	sysFcntl      = 72
+	sysFsync      = 74
+	sysFtruncate  = 77
+	sysGetcwd     = 79
+	sysChdir      = 80
+	sysRename     = 82
+	sysMkdir      = 83
+	sysRmdir      = 84
+	sysUnlink     = 87
+	sysGetdents64 = 217
+	sysOpenat     = 257
+	sysMkdirat    = 258
+	sysNewfstatat = 262
+	sysUnlinkat   = 263
+	sysRenameat   = 264
+)
+
+// Error numbers returned by the system calls
+const (
+	errENOENT    = 2
+	errEBADF     = 9
+	errEACCES    = 13
+	errEEXIST    = 17
+	errENOTDIR   = 20
+	errEISDIR    = 21
+	errEINVAL    = 22
+	errENOTEMPTY = 39
+)
+
+// Flags for open
+const (
+	flagAccess = 0x3
+	flagRdonly = 0x0
+	flagWronly = 0x1
+	flagCreat  = 0x40
+	flagExcl   = 0x80
+	flagTrunc  = 0x200
+	flagAppend = 0x400
+)
+
+// atRemovedir is the unlinkat flag to remove a directory
+const atRemovedir = 0x200
+
+func NewFilesystemStore(app *App) *FilesystemStore {
+	s := &FilesystemStore{
+		app: app,
+	}
+	s.reset("/")
+	return s
+}
+
+// FilesystemStore is the virtual filesystem of the running program. The syscall shim in the iframe
+// passes the file system calls to syscall. Before each run it's populated with the files in the
+// project that aren't Go source, in a directory for each package (e.g. /main/data.json), and the
+// working directory is the directory of the package that is run. Files that the program writes are
+// kept after the run, so they can be viewed and downloaded.
+type FilesystemStore struct {
+	app *App
+
+	nodes   map[string]*fsNode
+	handles map[int]*fsHandle
+	next    int    // next file descriptor
+	inode   uint64 // last inode number
+	cwd     string
+	written map[string]bool // files written by the program
+	viewing string          // file shown in the files modal
+
+	target     *fsTarget
+	targetHash string // hash of the syscall archive the target was read from
+}
+
+type fsNode struct {
+	dir      bool
+	data     []byte
+	inode    uint64
+	modified time.Time
+}
+
+type fsHandle struct {
+	name   string
+	node   *fsNode
+	flags  int
+	offset int
+	listed bool // the directory entries have been read by getdents
+}
+
+// Written returns the files written by the program, sorted
+func (s *FilesystemStore) Written() []string {
+	var names []string
+	for name := range s.written {
+		if _, ok := s.nodes[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Contents returns the contents of a file
+func (s *FilesystemStore) Contents(name string) []byte {
+	if n, ok := s.nodes[name]; ok {
+		return n.data
+	}
+	return nil
+}
+
+// Viewing is the file shown in the files modal
+func (s *FilesystemStore) Viewing() string {
+	return s.viewing
+}
+
+func (s *FilesystemStore) Handle(payload *flux.Payload) bool {
+	switch a := payload.Action.(type) {
+	case *actions.ViewFile:
+		s.viewing = a.Name
+		payload.Notify()
+	case *actions.DownloadFile:
+		n, ok := s.nodes[a.Name]
+		if !ok || n.dir {
+			return true
+		}
+		b := make([]byte, len(n.data))
+		copy(b, n.data)
+		blob := js.Global.Get("Blob").New([]interface{}{js.InternalObject(b).Get("$array")})
+		url := js.Global.Get("URL").Call("createObjectURL", blob)
+		anchor := js.Global.Get("document").Call("createElement", "a")
+		anchor.Set("href", url)
+		anchor.Set("download", path.Base(a.Name))
+		anchor.Call("click")
+		js.Global.Get("URL").Call("revokeObjectURL", url)
+	}
+	return true
+}
+
+// start populates the file system for a run of the package in dir
+func (s *FilesystemStore) start(dir string) {
+	s.reset("/" + dir)
+	if c, ok := s.app.Archive.Cache()["syscall"]; ok && c.Archive != nil && c.Hash != s.targetHash {
+		target, err := newFsTarget(c.Archive)
+		if err != nil {
+			s.app.Warn(models.RuntimeSource, fmt.Errorf("error reading system calls: %v", err))
+			target = amd64Target
+		}
+		s.target, s.targetHash = target, c.Hash
+	}
+	for _, p := range s.app.Source.Packages() {
+		s.mkdirAll("/" + p)
+		for name, contents := range s.app.Source.Files(p) {
+			if strings.HasSuffix(name, ".go") {
+				continue
+			}
+			s.create("/" + p + "/" + name).data = []byte(contents)
+		}
+	}
+	s.mkdirAll(s.cwd)
+}
+
+func (s *FilesystemStore) reset(cwd string) {
+	s.nodes = map[string]*fsNode{}
+	s.handles = map[int]*fsHandle{}
+	s.next = 3
+	s.cwd = cwd
+	s.written = map[string]bool{}
+	s.viewing = ""
+	s.mkdirAll("/")
+}
+
+func (s *FilesystemStore) mkdirAll(name string) {
+	if _, ok := s.nodes[name]; ok {
+		return
+	}
+	if name != "/" {
+		s.mkdirAll(path.Dir(name))
+	}
+	s.inode++
+	s.nodes[name] = &fsNode{dir: true, inode: s.inode, modified: time.Now()}
+}
+
+// create adds an empty file, creating the directories if needed
+func (s *FilesystemStore) create(name string) *fsNode {
+	s.mkdirAll(path.Dir(name))
+	s.inode++
+	n := &fsNode{inode: s.inode, modified: time.Now()}
+	s.nodes[name] = n
+	return n
+}
+
+// abs returns the absolute path of the null terminated path in the Uint8Array arr
+func (s *FilesystemStore) abs(arr *js.Object) string {
+	name := string(fsBytes(arr))
+	if i := strings.IndexByte(name, 0); i > -1 {
+		name = name[:i]
+	}
+	if !path.IsAbs(name) {
+		name = path.Join(s.cwd, name)
+	}
+	return path.Clean(name)
+}
+
+// children returns the names of the entries in the directory dir, sorted
+func (s *FilesystemStore) children(dir string) []string {
+	var names []string
+	for name := range s.nodes {
+		if name != "/" && path.Dir(name) == dir {
+			names = append(names, path.Base(name))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// syscall handles a system call from the program. The arguments are numbers, or a Uint8Array for
+// pointers (GopherJS converts structs to and from a Uint8Array with the struct's memory layout). It
+// returns r1, r2 and the error number. This is called from JS, so mustn't block.
+func (s *FilesystemStore) syscall(trap int, a1, a2, a3, a4 *js.Object) []int {
+	r, errno := s.call(s.trap(trap), a1, a2, a3, a4)
+	if errno != 0 {
+		return []int{-1, 0, errno}
+	}
+	return []int{r, 0, 0}
+}
+
+// trap returns the linux/amd64 number of the system call trap of the target
+func (s *FilesystemStore) trap(trap int) int {
+	if s.target == nil || s.target.traps == nil {
+		return trap
+	}
+	if t, ok := s.target.traps[trap]; ok {
+		return t
+	}
+	// not used by the file system
+	return -1
+}
+
+func (s *FilesystemStore) call(trap int, a1, a2, a3, a4 *js.Object) (int, int) {
+	switch trap {
+	case sysOpen:
+		return s.open(s.abs(a1), a2.Int())
+	case sysOpenat:
+		return s.open(s.abs(a2), a3.Int())
+	case sysClose:
+		if _, ok := s.handles[a1.Int()]; !ok {
+			return 0, errEBADF
+		}
+		delete(s.handles, a1.Int())
+		return 0, 0
+	case sysRead, sysPread:
+		h, ok := s.handles[a1.Int()]
+		if !ok {
+			return 0, errEBADF
+		}
+		if h.node.dir {
+			return 0, errEISDIR
+		}
+		if h.flags&flagAccess == flagWronly {
+			return 0, errEBADF
+		}
+		offset := h.offset
+		if trap == sysPread {
+			offset = a4.Int()
+		}
+		if offset >= len(h.node.data) {
+			return 0, 0
+		}
+		n := fsCopy(a2, h.node.data[offset:])
+		if trap == sysRead {
+			h.offset += n
+		}
+		return n, 0
+	case sysWrite, sysPwrite:
+		h, ok := s.handles[a1.Int()]
+		if !ok {
+			return 0, errEBADF
+		}
+		if h.flags&flagAccess == flagRdonly {
+			return 0, errEBADF
+		}
+		b := fsBytes(a2)
+		if len(b) > a3.Int() {
+			b = b[:a3.Int()]
+		}
+		offset := h.offset
+		switch {
+		case trap == sysPwrite:
+			offset = a4.Int()
+		case h.flags&flagAppend != 0:
+			offset = len(h.node.data)
+		}
+		if end := offset + len(b); end > len(h.node.data) {
+			data := make([]byte, end)
+			copy(data, h.node.data)
+			h.node.data = data
+		}
+		copy(h.node.data[offset:], b)
+		h.node.modified = time.Now()
+		s.written[h.name] = true
+		if trap == sysWrite {
+			h.offset = offset + len(b)
+		}
+		return len(b), 0
+	case sysLseek:
+		h, ok := s.handles[a1.Int()]
+		if !ok {
+			return 0, errEBADF
+		}
+		offset := a2.Int()
+		switch a3.Int() {
+		case 1:
+			offset += h.offset
+		case 2:
+			offset += len(h.node.data)
+		}
+		if offset < 0 {
+			return 0, errEINVAL
+		}
+		h.offset = offset
+		return offset, 0
+	case sysStat, sysLstat:
+		return s.stat(s.abs(a1), a2)
+	case sysNewfstatat:
+		return s.stat(s.abs(a2), a3)
+	case sysFstat:
+		h, ok := s.handles[a1.Int()]
+		if !ok {
+			return 0, errEBADF
+		}
+		return s.stat(h.name, a2)
+	case sysAccess:
+		if _, ok := s.nodes[s.abs(a1)]; !ok {
+			return 0, errENOENT
+		}
+		return 0, 0
+	case sysFcntl, sysFsync:
+		if _, ok := s.handles[a1.Int()]; !ok {
+			return 0, errEBADF
+		}
+		return 0, 0
+	case sysFtruncate:
+		h, ok := s.handles[a1.Int()]
+		if !ok {
+			return 0, errEBADF
+		}
+		size := a2.Int()
+		data := make([]byte, size)
+		copy(data, h.node.data)
+		h.node.data = data
+		h.node.modified = time.Now()
+		s.written[h.name] = true
+		return 0, 0
+	case sysGetdents64:
+		return s.getdents(a1.Int(), a2)
+	case sysGetcwd:
+		b := append([]byte(s.cwd), 0)
+		if a2.Int() < len(b) {
+			return 0, errEINVAL
+		}
+		return fsCopy(a1, b), 0
+	case sysChdir:
+		name := s.abs(a1)
+		n, ok := s.nodes[name]
+		if !ok {
+			return 0, errENOENT
+		}
+		if !n.dir {
+			return 0, errENOTDIR
+		}
+		s.cwd = name
+		return 0, 0
+	case sysMkdir:
+		return s.mkdir(s.abs(a1))
+	case sysMkdirat:
+		return s.mkdir(s.abs(a2))
+	case sysUnlink:
+		return s.remove(s.abs(a1), false)
+	case sysRmdir:
+		return s.remove(s.abs(a1), true)
+	case sysUnlinkat:
+		return s.remove(s.abs(a2), a3.Int()&atRemovedir != 0)
+	case sysRename:
+		return s.rename(s.abs(a1), s.abs(a2))
+	case sysRenameat:
+		return s.rename(s.abs(a2), s.abs(a4))
+	}
+	return 0, errEACCES
+}
+
+func (s *FilesystemStore) open(name string, flags int) (int, int) {
+	n, ok := s.nodes[name]
+	switch {
+	case ok && flags&flagCreat != 0 && flags&flagExcl != 0:
+		return 0, errEEXIST
+	case !ok && flags&flagCreat == 0:
+		return 0, errENOENT
+	case !ok:
+		parent, ok := s.nodes[path.Dir(name)]
+		if !ok {
+			return 0, errENOENT
+		}
+		if !parent.dir {
+			return 0, errENOTDIR
+		}
+		n = s.create(name)
+		s.written[name] = true
+	case n.dir && flags&flagAccess != flagRdonly:
+		return 0, errEISDIR
+	case flags&flagTrunc != 0 && flags&flagAccess != flagRdonly:
+		n.data = nil
+		n.modified = time.Now()
+		s.written[name] = true
+	}
+	fd := s.next
+	s.next++
+	s.handles[fd] = &fsHandle{name: name, node: n, flags: flags}
+	return fd, 0
+}
+
+// stat writes the stat_t struct for the file to the Uint8Array arr
+func (s *FilesystemStore) stat(name string, arr *js.Object) (int, int) {
+	n, ok := s.nodes[name]
+	if !ok {
+		return 0, errENOENT
+	}
+	mode := uint64(0x8000 | 0644) // S_IFREG
+	if n.dir {
+		mode = 0x4000 | 0755 // S_IFDIR
+	}
+	nano := uint64(n.modified.UnixNano())
+	layout := amd64Target.stat
+	if s.target != nil {
+		layout = s.target.stat
+	}
+	view := js.Global.Get("DataView").New(arr.Get("buffer"), arr.Get("byteOffset"), arr.Get("byteLength"))
+	set := func(field string, value uint64) {
+		f, ok := layout[field]
+		if !ok {
+			return
+		}
+		view.Call("setUint32", f.offset, uint32(value), true)
+		if f.size == 8 {
+			view.Call("setUint32", f.offset+4, uint32(value>>32), true)
+		}
+	}
+	set("Ino", n.inode)
+	set("Nlink", 1)
+	set("Mode", mode)
+	set("Size", uint64(len(n.data)))
+	set("Blksize", 4096)
+	set("Blocks", uint64(len(n.data)+511)/512)
+	for _, field := range []string{"Atim", "Mtim", "Ctim"} {
+		// Timespec is two int64s
+		f := layout[field]
+		view.Call("setUint32", f.offset, uint32(nano/1e9), true)
+		view.Call("setUint32", f.offset+4, uint32(nano/1e9>>32), true)
+		view.Call("setUint32", f.offset+8, uint32(nano%1e9), true)
+	}
+	return 0, 0
+}
+
+// getdents writes all the entries of the directory to the Uint8Array arr as linux_dirent64
+// structs. The next call returns 0, which is the end of the directory.
+func (s *FilesystemStore) getdents(fd int, arr *js.Object) (int, int) {
+	h, ok := s.handles[fd]
+	if !ok {
+		return 0, errEBADF
+	}
+	if !h.node.dir {
+		return 0, errENOTDIR
+	}
+	if h.listed {
+		return 0, 0
+	}
+	var b []byte
+	for _, child := range s.children(h.name) {
+		n := s.nodes[path.Join(h.name, child)]
+		reclen := (19 + len(child) + 1 + 7) / 8 * 8
+		rec := make([]byte, reclen)
+		for i := 0; i < 8; i++ {
+			rec[i] = byte(n.inode >> uint(8*i)) // d_ino
+		}
+		rec[16], rec[17] = byte(reclen), byte(reclen>>8) // d_reclen
+		rec[18] = 8                                      // d_type = DT_REG
+		if n.dir {
+			rec[18] = 4 // DT_DIR
+		}
+		copy(rec[19:], child)
+		b = append(b, rec...)
+	}
+	if len(b) > arr.Length() {
+		return 0, errEINVAL
+	}
+	h.listed = true
+	return fsCopy(arr, b), 0
+}
+
+func (s *FilesystemStore) mkdir(name string) (int, int) {
+	if _, ok := s.nodes[name]; ok {
+		return 0, errEEXIST
+	}
+	parent, ok := s.nodes[path.Dir(name)]
+	if !ok {
+		return 0, errENOENT
+	}
+	if !parent.dir {
+		return 0, errENOTDIR
+	}
+	s.mkdirAll(name)
+	return 0, 0
+}
+
+func (s *FilesystemStore) remove(name string, dir bool) (int, int) {
+	n, ok := s.nodes[name]
+	switch {
+	case !ok:
+		return 0, errENOENT
+	case dir && !n.dir:
+		return 0, errENOTDIR
+	case !dir && n.dir:
+		return 0, errEISDIR
+	case dir && len(s.children(name)) > 0:
+		return 0, errENOTEMPTY
+	}
+	delete(s.nodes, name)
+	delete(s.written, name)
+	return 0, 0
+}
+
+func (s *FilesystemStore) rename(from, to string) (int, int) {
+	n, ok := s.nodes[from]
+	if !ok {
+		return 0, errENOENT
+	}
+	if _, ok := s.nodes[path.Dir(to)]; !ok {
+		return 0, errENOENT
+	}
+	if n.dir {
+		// move everything in the directory
+		for name, child := range s.nodes {
+			if strings.HasPrefix(name, from+"/") {
+				delete(s.nodes, name)
+				s.nodes[to+strings.TrimPrefix(name, from)] = child
+				if s.written[name] {
+					delete(s.written, name)
+					s.written[to+strings.TrimPrefix(name, from)] = true
+				}
+			}
+		}
+	} else {
+		s.written[to] = true
+	}
+	delete(s.nodes, from)
+	delete(s.written, from)
+	s.nodes[to] = n
+	return 0, 0
+}
+
+// fsBytes copies the contents of the Uint8Array arr
+func fsBytes(arr *js.Object) []byte {
+	b := make([]byte, arr.Length())
+	js.InternalObject(b).Get("$array").Call("set", arr)
+	return b
+}
+
+// fsCopy copies b to the Uint8Array arr, and returns the number of bytes copied
+func fsCopy(arr *js.Object, b []byte) int {
+	n := arr.Length()
+	if n > len(b) {
+		n = len(b)
+	}
+	c := make([]byte, n)
+	copy(c, b)
+	arr.Call("set", js.InternalObject(c).Get("$array"))
+	return n
+}
+
+// fsTarget is the system call numbers and the stat_t layout of the target the standard library was
+// compiled for
+type fsTarget struct {
+	traps map[int]int        // target number -> linux/amd64 number
+	stat  map[string]fsField // Stat_t field -> offset and size
+}
+
+type fsField struct {
+	offset, size int
+}
+
+// amd64Target is linux/amd64, which is used if the syscall archive can't be read. The numbers don't
+// need translating.
+var amd64Target = &fsTarget{
+	stat: map[string]fsField{
+		"Ino":     {8, 8},
+		"Nlink":   {16, 8},
+		"Mode":    {24, 4},
+		"Size":    {48, 8},
+		"Blksize": {56, 8},
+		"Blocks":  {64, 8},
+		"Atim":    {72, 16},
+		"Mtim":    {88, 16},
+		"Ctim":    {104, 16},
+	},
+}
+
+// fsTraps are the names of the system calls in the syscall package
+var fsTraps = map[string]int{
+	"SYS_READ":       sysRead,
+	"SYS_WRITE":      sysWrite,
+	"SYS_OPEN":       sysOpen,
+	"SYS_CLOSE":      sysClose,
+	"SYS_STAT":       sysStat,
+	"SYS_FSTAT":      sysFstat,
+	"SYS_LSTAT":      sysLstat,
+	"SYS_LSEEK":      sysLseek,
+	"SYS_PREAD64":    sysPread,
+	"SYS_PWRITE64":   sysPwrite,
+	"SYS_ACCESS":     sysAccess,
+	"SYS_FCNTL":      sysFcntl,
+	"SYS_FSYNC":      sysFsync,
+	"SYS_FTRUNCATE":  sysFtruncate,
+	"SYS_GETCWD":     sysGetcwd,
+	"SYS_CHDIR":      sysChdir,
+	"SYS_RENAME":     sysRename,
+	"SYS_MKDIR":      sysMkdir,
+	"SYS_RMDIR":      sysRmdir,
+	"SYS_UNLINK":     sysUnlink,
+	"SYS_GETDENTS64": sysGetdents64,
+	"SYS_OPENAT":     sysOpenat,
+	"SYS_MKDIRAT":    sysMkdirat,
+	"SYS_NEWFSTATAT": sysNewfstatat,
+	"SYS_FSTATAT":    sysNewfstatat, // the name on some architectures (e.g. arm64)
+	"SYS_UNLINKAT":   sysUnlinkat,
+	"SYS_RENAMEAT":   sysRenameat,
+}
+
+// newFsTarget reads the system call numbers and the layout of Stat_t from the export data of the
+// syscall archive. Architectures without a system call (e.g. SYS_OPEN on arm64) use another (e.g.
+// SYS_OPENAT).
+func newFsTarget(a *compiler.Archive) (*fsTarget, error) {
+	p, err := gcexportdata.Read(bytes.NewReader(a.ExportData), token.NewFileSet(), map[string]*types.Package{}, "syscall")
+	if err != nil {
+		return nil, err
+	}
+	t := &fsTarget{traps: map[int]int{}, stat: map[string]fsField{}}
+	for name, trap := range fsTraps {
+		c, ok := p.Scope().Lookup(name).(*types.Const)
+		if !ok {
+			continue
+		}
+		if v, ok := constant.Int64Val(c.Val()); ok {
+			t.traps[int(v)] = trap
+		}
+	}
+	stat, ok := p.Scope().Lookup("Stat_t").(*types.TypeName)
+	if !ok {
+		return nil, errors.New("syscall.Stat_t not found")
+	}
+	st, ok := stat.Type().Underlying().(*types.Struct)
+	if !ok {
+		return nil, errors.New("syscall.Stat_t is not a struct")
+	}
+	// the layout GopherJS uses to convert structs to a Uint8Array
+	sizes := &types.StdSizes{WordSize: 4, MaxAlign: 8}
+	fields := make([]*types.Var, st.NumFields())
+	for i := range fields {
+		fields[i] = st.Field(i)
+	}
+	for i, offset := range sizes.Offsetsof(fields) {
+		t.stat[fields[i].Name()] = fsField{offset: int(offset), size: int(sizes.Sizeof(fields[i].Type()))}
+	}
+	return t, nil
+}
@@ -0,0 +1,278 @@
+package stores
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/dave/play/models"
+	"github.com/gopherjs/gopherjs/js"
+	"honnef.co/go/js/dom"
+)
+
+// frameRunner runs each program in a new iframe in #iframe-holder
+type frameRunner struct {
+	app *App
+}
+
+// Stop removes the iframe, which stops the running program
+func (r *frameRunner) Stop() {
+	holder := dom.GetWindow().Document().GetElementByID("iframe-holder")
+	for _, v := range holder.ChildNodes() {
+		v.Underlying().Call("remove")
+	}
+}
+
+// Run creates a new iframe and runs program. If program.Index is not empty, it is used as a template
+// for the iframe contents.
+func (r *frameRunner) Run(program Program) error {
+	r.Stop()
+
+	doc := dom.GetWindow().Document()
+	holder := doc.GetElementByID("iframe-holder")
+	frame := doc.CreateElement("iframe").(*dom.HTMLIFrameElement)
+	frame.SetID("iframe")
+	frame.Style().Set("width", "100%")
+	frame.Style().Set("height", "100%")
+	frame.Style().Set("border", "0")
+
+	// We need to wait for the iframe to load before adding contents or Firefox will clear the iframe
+	// after momentarily flashing up the contents.
+	c := make(chan struct{})
+	listener := frame.AddEventListener("load", false, func(event dom.Event) {
+		close(c)
+	})
+
+	holder.AppendChild(frame)
+	<-c
+
+	// remove the listener so if it's triggered again we don't close the closed channel
+	frame.RemoveEventListener("load", false, listener)
+
+	r.app.Console.start(newSourceMaps(r.app, program.Deps))
+	window := frame.Get("contentWindow")
+	// goWrite is called by the syscall shim (see syscallShim and wasmShim) with writes to stdout and
+	// stderr
+	window.Set("goWrite", func(fd int, data *js.Object) {
+		b := make([]byte, data.Length())
+		js.InternalObject(b).Get("$array").Call("set", data)
+		if fd == 2 {
+			r.app.Console.write(Stderr, string(b))
+		} else {
+			r.app.Console.write(Stdout, string(b))
+		}
+	})
+	// goRead is called by the syscall shim with reads from stdin
+	window.Set("goRead", func(data *js.Object) int {
+		return r.app.Console.read(data)
+	})
+	// goReadWait is called with reads from stdin that wait for input, by the wasm shim and by
+	// syscall.Read in the standard library compiled by playserver (see cmd/playserver/natives.go)
+	window.Set("goReadWait", func(data, callback *js.Object) {
+		r.app.Console.wait(data, func(n int) { callback.Invoke(n) })
+	})
+	// uncaught errors (e.g. panics) are printed with their stack trace
+	window.Set("goReportError", func(stack string) {
+		r.app.Console.write(Stderr, stack+"\n")
+		r.app.Warn(models.RuntimeSource, errors.New(strings.SplitN(stack, "\n", 2)[0]))
+	})
+	// os.Args and the environment
+	window.Set("goArgs", program.Args)
+	window.Set("goEnv", program.Env)
+
+	frameDoc := frame.ContentDocument()
+
+	if program.Index != "" {
+		// has index
+
+		indexTemplate, err := template.New("index").Parse(program.Index)
+		if err != nil {
+			return err
+		}
+		data := struct{ Script string }{Script: ""}
+		buf := &bytes.Buffer{}
+		if err := indexTemplate.Execute(buf, data); err != nil {
+			return err
+		}
+
+		frameDoc.Underlying().Call("open")
+		frameDoc.Underlying().Call("write", buf.String())
+		frameDoc.Underlying().Call("close")
+	}
+
+	head := frameDoc.GetElementsByTagName("head")[0].(*dom.BasicHTMLElement)
+
+	if program.Wasm != nil {
+		r.loadWasm(frameDoc, head, window, program.Wasm)
+	} else {
+		r.loadJs(frameDoc, head, window, program.Path, program.Deps)
+	}
+	return nil
+}
+
+// loadJs adds the scripts that run a program compiled by GopherJS to the iframe
+func (r *frameRunner) loadJs(frameDoc dom.Document, head dom.Element, window *js.Object, path string, deps []models.Dep) {
+	// goTrap is called by the syscall shim to find the system call (see FilesystemStore.trap)
+	window.Set("goTrap", func(trap int) int {
+		return r.app.Filesystem.trap(trap)
+	})
+	// goSyscall is called by the syscall shim with the other system calls
+	window.Set("goSyscall", func(trap int, a1, a2, a3, a4 *js.Object) []int {
+		return r.app.Filesystem.syscall(trap, a1, a2, a3, a4)
+	})
+	// goPrintToConsole is used by GopherJS if the shim isn't
+	window.Set("goPrintToConsole", js.InternalObject(func(b []byte) {
+		r.app.Console.write(Stdout, string(b))
+	}))
+
+	loaderJs := ""
+	for _, dep := range deps {
+		loaderJs += "$load[" + strconv.Quote(dep.Path) + "]();\n"
+	}
+	scriptLoad := frameDoc.CreateElement("script")
+	scriptLoad.SetID("loader")
+	scriptLoad.SetInnerHTML(syscallShim + `
+		// the GopherJS runtime reads os.Args and the environment from process
+		window.process = {argv: ["play"].concat(goArgs), env: goEnv};
+		window.addEventListener("error", function(e) {
+			goReportError(e.error && e.error.stack ? e.error.stack : e.message);
+		});
+		var $load = {};
+		var $count = 0;
+		var $total = ` + fmt.Sprint(len(deps)) + `;
+		var $finished = function() {
+			` + loaderJs + `
+			$mainPkg = $packages[` + strconv.Quote(path) + `];
+			$synthesizeMethods();
+			$packages["runtime"].$init();
+			$go($mainPkg.$init, []);
+			$flushConsole();
+		};
+		var $done = function() {
+			$count++;
+			if ($count == $total) {
+				$finished();
+			}
+		};
+	`)
+	head.AppendChild(scriptLoad)
+
+	for _, dep := range deps {
+		scriptDep := frameDoc.CreateElement("script")
+		scriptDep.SetID(dep.Path)
+		// sourceURL names the script in stack traces, and the source map lets the browser's
+		// developer tools show the Go source
+		code := string(dep.Js) + "$done();\n//# sourceURL=" + scriptURL(dep.Path)
+		if dep.SourceMap != nil {
+			code += "\n//# sourceMappingURL=data:application/json;base64," + base64.StdEncoding.EncodeToString(dep.SourceMap)
+		}
+		scriptDep.SetInnerHTML(code)
+		//scriptDep.AppendChild(doc.CreateTextNode(string(dep.Js) + "$done();"))
+		head.AppendChild(scriptDep)
+	}
+}
+
+// loadWasm adds the scripts that run a program compiled to WebAssembly to the iframe
+func (r *frameRunner) loadWasm(frameDoc dom.Document, head dom.Element, window *js.Object, wasm *models.Wasm) {
+	binary := js.InternalObject(wasm.Binary)
+	offset := binary.Get("$offset").Int()
+	window.Set("goWasm", binary.Get("$array").Call("subarray", offset, offset+len(wasm.Binary)))
+
+	script := frameDoc.CreateElement("script")
+	script.SetID("loader")
+	script.SetInnerHTML(wasmShim + string(wasm.Support) + `
+		var go = new Go();
+		go.argv = goArgs;
+		go.env = goEnv;
+		WebAssembly.instantiate(goWasm, go.importObject).then(function(result) {
+			return go.run(result.instance);
+		}).catch(function(err) {
+			goReportError(err && err.stack ? err.stack : String(err));
+		});
+	`)
+	head.AppendChild(script)
+}
+
+// wasmShim is run in the iframe before wasm_exec.js. The Go WebAssembly runtime makes system calls
+// with the global fs object (the node.js fs module), so this passes writes to stdout and stderr to
+// goWrite, and reads from stdin to goReadWait, which calls back when there's input. Other calls fail
+// with ENOSYS, as they do in wasm_exec.js without node.js, so the virtual file system isn't
+// available.
+const wasmShim = `
+	var enosys = function() {
+		var err = new Error("not implemented");
+		err.code = "ENOSYS";
+		return err;
+	};
+	window.fs = {
+		constants: {O_WRONLY: -1, O_RDWR: -1, O_CREAT: -1, O_TRUNC: -1, O_APPEND: -1, O_EXCL: -1},
+		writeSync: function(fd, buf) {
+			if (fd !== 1 && fd !== 2) {
+				throw enosys();
+			}
+			goWrite(fd, buf);
+			return buf.length;
+		},
+		write: function(fd, buf, offset, length, position, callback) {
+			if (position !== null) {
+				callback(enosys());
+				return;
+			}
+			try {
+				callback(null, window.fs.writeSync(fd, buf.subarray(offset, offset + length)));
+			} catch (err) {
+				callback(err);
+			}
+		},
+		read: function(fd, buf, offset, length, position, callback) {
+			if (fd !== 0 || position !== null) {
+				callback(enosys());
+				return;
+			}
+			goReadWait(buf.subarray(offset, offset + length), function(n) {
+				// the callback resumes the program, so it's called after the read has returned
+				setTimeout(function() { callback(null, n); });
+			});
+		},
+	};
+	["chmod", "chown", "close", "fchmod", "fchown", "fstat", "fsync", "ftruncate", "lchown", "link",
+		"lstat", "mkdir", "open", "readdir", "readlink", "rename", "rmdir", "stat", "symlink",
+		"truncate", "unlink", "utimes"].forEach(function(name) {
+		window.fs[name] = function() {
+			// the last argument is the callback
+			arguments[arguments.length - 1](enosys());
+		};
+	});
+	window.addEventListener("error", function(e) {
+		goReportError(e.error && e.error.stack ? e.error.stack : e.message);
+	});
+`
+
+// syscallShim is run in the iframe before the program. GopherJS uses the "syscall" module from
+// require (in node.js) if it's available, so this receives the writes to stdout and stderr with the
+// file descriptor, and passes them to goWrite, and the reads from stdin, which are passed to goRead.
+// Other system calls are passed to goSyscall, which is the virtual file system.
+const syscallShim = `
+	window.require = function(name) {
+		if (name !== "syscall") {
+			throw new Error("Cannot find module '" + name + "'");
+		}
+		var syscall = function(trap, a1, a2, a3, a4, a5, a6) {
+			// goTrap translates the number to linux/amd64, where SYS_WRITE is 1 and SYS_READ is 0
+			var t = goTrap(trap);
+			if (t === 1 && (a1 === 1 || a1 === 2) && a2 instanceof Uint8Array) {
+				goWrite(a1, a2);
+				return [a2.length, 0, 0];
+			}
+			if (t === 0 && a1 === 0 && a2 instanceof Uint8Array) {
+				return [goRead(a2), 0, 0];
+			}
+			return goSyscall(trap, a1, a2, a3, a4);
+		};
+		return {Syscall: syscall, Syscall6: syscall, RawSyscall: syscall, RawSyscall6: syscall};
+	};
+`
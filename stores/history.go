@@ -3,7 +3,6 @@ package stores
 import (
 	"github.com/dave/flux"
 	"github.com/dave/play/actions"
-	"github.com/gopherjs/gopherjs/js"
 )
 
 func NewHistoryStore(app *App) *HistoryStore {
@@ -25,11 +24,13 @@ func (s *HistoryStore) Handle(payload *flux.Payload) bool {
 		*actions.AddPackage,
 		*actions.RemovePackage,
 		*actions.DragDrop,
-		*actions.BuildTags:
-		js.Global.Get("history").Call("replaceState", js.M{}, "", "/")
+		*actions.RestoreSource,
+		*actions.BuildTags,
+		*actions.RunConfigs:
+		s.app.Location.Replace("/")
 	case *actions.LoadSource:
 		if a.Save {
-			js.Global.Get("history").Call("replaceState", js.M{}, "", "/")
+			s.app.Location.Replace("/")
 		}
 	}
 	return true
@@ -0,0 +1,169 @@
+// Package idb is a minimal blocking wrapper around IndexedDB. Values are strings or byte slices, and
+// keys are strings, so records can be listed by key prefix. The functions block until the request
+// completes, so they must not be called from a JS callback.
+package idb
+
+import (
+	"errors"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// DB is an open IndexedDB database. The stores use it through the stores.Database interface.
+type DB struct {
+	db *js.Object
+}
+
+// Open opens database name, creating the object stores if they don't exist.
+func Open(name string, version int, stores ...string) (*DB, error) {
+	factory := js.Global.Get("indexedDB")
+	if factory == js.Undefined || factory == nil {
+		return nil, errors.New("IndexedDB is not supported by this browser")
+	}
+	req := factory.Call("open", name, version)
+	req.Set("onupgradeneeded", func() {
+		db := req.Get("result")
+		for _, store := range stores {
+			if !db.Get("objectStoreNames").Call("contains", store).Bool() {
+				db.Call("createObjectStore", store)
+			}
+		}
+	})
+	result, err := wait(req)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{db: result}, nil
+}
+
+// Get returns the value of key in store.
+func (d *DB) Get(store, key string) (value string, found bool, err error) {
+	tx := d.db.Call("transaction", store, "readonly")
+	result, err := wait(tx.Call("objectStore", store).Call("get", key))
+	if err != nil {
+		return "", false, err
+	}
+	if result == js.Undefined || result == nil {
+		return "", false, nil
+	}
+	return result.String(), true, nil
+}
+
+// Prefix returns all records in store with a key starting with prefix.
+func (d *DB) Prefix(store, prefix string) (map[string]string, error) {
+	tx := d.db.Call("transaction", store, "readonly")
+	s := tx.Call("objectStore", store)
+	keys, err := wait(s.Call("getAllKeys", prefixRange(prefix)))
+	if err != nil {
+		return nil, err
+	}
+	values, err := wait(s.Call("getAll", prefixRange(prefix)))
+	if err != nil {
+		return nil, err
+	}
+	records := map[string]string{}
+	for i := 0; i < keys.Length(); i++ {
+		records[keys.Index(i).String()] = values.Index(i).String()
+	}
+	return records, nil
+}
+
+// Update writes the put records and deletes the del keys in a single transaction.
+func (d *DB) Update(store string, put map[string]string, del []string) error {
+	values := map[string]interface{}{}
+	for key, value := range put {
+		values[key] = value
+	}
+	return d.update(store, values, del)
+}
+
+// PrefixBytes is Prefix for stores with byte slice values.
+func (d *DB) PrefixBytes(store, prefix string) (map[string][]byte, error) {
+	tx := d.db.Call("transaction", store, "readonly")
+	s := tx.Call("objectStore", store)
+	keys, err := wait(s.Call("getAllKeys", prefixRange(prefix)))
+	if err != nil {
+		return nil, err
+	}
+	values, err := wait(s.Call("getAll", prefixRange(prefix)))
+	if err != nil {
+		return nil, err
+	}
+	records := map[string][]byte{}
+	for i := 0; i < keys.Length(); i++ {
+		value := js.Global.Get("Uint8Array").New(values.Index(i))
+		b := make([]byte, value.Length())
+		js.InternalObject(b).Get("$array").Call("set", value)
+		records[keys.Index(i).String()] = b
+	}
+	return records, nil
+}
+
+// UpdateBytes is Update for stores with byte slice values.
+func (d *DB) UpdateBytes(store string, put map[string][]byte, del []string) error {
+	values := map[string]interface{}{}
+	for key, value := range put {
+		values[key] = value
+	}
+	return d.update(store, values, del)
+}
+
+func (d *DB) update(store string, put map[string]interface{}, del []string) error {
+	if len(put) == 0 && len(del) == 0 {
+		return nil
+	}
+	tx := d.db.Call("transaction", store, "readwrite")
+	s := tx.Call("objectStore", store)
+	for _, key := range del {
+		s.Call("delete", key)
+	}
+	for key, value := range put {
+		s.Call("put", value, key)
+	}
+	return complete(tx)
+}
+
+// DeletePrefix deletes all records in store with a key starting with prefix.
+func (d *DB) DeletePrefix(store, prefix string) error {
+	tx := d.db.Call("transaction", store, "readwrite")
+	tx.Call("objectStore", store).Call("delete", prefixRange(prefix))
+	return complete(tx)
+}
+
+func prefixRange(prefix string) *js.Object {
+	return js.Global.Get("IDBKeyRange").Call("bound", prefix, prefix+"\uffff")
+}
+
+// wait blocks until req succeeds or fails.
+func wait(req *js.Object) (*js.Object, error) {
+	done := make(chan error, 1)
+	req.Set("onsuccess", func() { done <- nil })
+	req.Set("onerror", func() { done <- domError(req.Get("error")) })
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	return req.Get("result"), nil
+}
+
+// complete blocks until transaction tx completes or fails.
+func complete(tx *js.Object) error {
+	done := make(chan error, 1)
+	finish := func(err error) {
+		// onerror is followed by onabort, so only the first result is used
+		select {
+		case done <- err:
+		default:
+		}
+	}
+	tx.Set("oncomplete", func() { finish(nil) })
+	tx.Set("onerror", func() { finish(domError(tx.Get("error"))) })
+	tx.Set("onabort", func() { finish(domError(tx.Get("error"))) })
+	return <-done
+}
+
+func domError(e *js.Object) error {
+	if e == js.Undefined || e == nil {
+		return errors.New("IndexedDB request failed")
+	}
+	return errors.New(e.Get("message").String())
+}
@@ -1,8 +1,6 @@
 package stores
 
 import (
-	"fmt"
-	"net/http"
 	"strings"
 
 	"encoding/json"
@@ -11,23 +9,19 @@ import (
 
 	"github.com/dave/flux"
 	"github.com/dave/jsgo/config"
-	"github.com/dave/locstor"
 	"github.com/dave/play/actions"
 	"github.com/dave/play/models"
-	"honnef.co/go/js/dom"
 )
 
 type LocalStore struct {
 	app *App
 
-	local       *locstor.DataStore
 	initialized bool
 }
 
 func NewLocalStore(app *App) *LocalStore {
 	s := &LocalStore{
-		app:   app,
-		local: locstor.NewDataStore(locstor.JSONEncoding),
+		app: app,
 	}
 	return s
 }
@@ -39,10 +33,14 @@ func (s *LocalStore) Initialized() bool {
 func (s *LocalStore) Handle(payload *flux.Payload) bool {
 	switch action := payload.Action.(type) {
 	case *actions.Load:
+		// the backend must be configured before any requests are made
+		payload.Wait(s.app.Backend)
+		payload.Wait(s.app.Workspace)
+
 		var sizes []float64
-		found, err := s.local.Find("split-sizes", &sizes)
+		found, err := s.app.Storage.Find("split-sizes", &sizes)
 		if err != nil {
-			s.app.Fail(err)
+			s.app.Fail(models.StorageSource, err)
 			return true
 		}
 		if !found {
@@ -50,68 +48,43 @@ func (s *LocalStore) Handle(payload *flux.Payload) bool {
 		}
 		s.app.Dispatch(&actions.ChangeSplit{Sizes: sizes})
 
-		location := strings.Trim(dom.GetWindow().Location().Pathname, "/")
+		location := strings.Trim(s.app.Location.URL().Path, "/")
 
 		var seenHelp bool
-		if _, err := s.local.Find("seen-help", &seenHelp); err != nil {
-			s.app.Fail(err)
+		if _, err := s.app.Storage.Find("seen-help", &seenHelp); err != nil {
+			s.app.Fail(models.StorageSource, err)
 			return true
 		}
 		if !seenHelp {
 			s.app.Dispatch(&actions.ModalOpen{Modal: models.HelpModal})
-			if err := s.local.Save("seen-help", true); err != nil {
-				s.app.Fail(err)
+			if err := s.app.Storage.Save("seen-help", true); err != nil {
+				s.app.Fail(models.StorageSource, err)
 				return true
 			}
 		}
 
-		// No page path -> load files from local storage or use default files
+		// No page path -> load files from the current workspace or use default files
 		if location == "" {
-			var currentPackage, currentFile string
-			var buildTags []string
-			var source map[string]map[string]string
-			found, err = s.local.Find("source", &source)
+			data, found, err := s.app.Workspace.Data()
 			if err != nil {
-				s.app.Fail(err)
+				s.app.Fail(models.StorageSource, err)
 				return true
 			}
 			if !found {
-				// old format for storing files
-				var files map[string]string
-				found, err = s.local.Find("files", &files)
-				if err != nil {
-					s.app.Fail(err)
-					return true
-				}
-				if found {
-					source = map[string]map[string]string{"main": files}
+				// if we didn't find a workspace in the local storage, add the default file
+				data = models.WorkspaceData{
+					Source:         map[string]map[string]string{"main": {"main.go": defaultFile}},
+					CurrentPackage: "main",
+					CurrentFile:    "main.go",
 				}
 			}
-			if found {
-				// if we found files in local storage, also load the current file and package
-				if _, err := s.local.Find("current-file", &currentFile); err != nil {
-					s.app.Fail(err)
-					return true
-				}
-				if _, err := s.local.Find("current-package", &currentPackage); err != nil {
-					s.app.Fail(err)
-					return true
-				}
-				if _, err := s.local.Find("build-tags", &buildTags); err != nil {
-					s.app.Fail(err)
-					return true
-				}
-			} else {
-				// if we didn't find source in the local storage, add the default file
-				source = map[string]map[string]string{"main": {"main.go": defaultFile}}
-				currentFile = "main.go"
-				currentPackage = "main"
-			}
 			s.app.Dispatch(&actions.LoadSource{
-				Source:         source,
-				CurrentFile:    currentFile,
-				CurrentPackage: currentPackage,
-				Tags:           buildTags,
+				Source:         data.Source,
+				CurrentFile:    data.CurrentFile,
+				CurrentPackage: data.CurrentPackage,
+				Tags:           data.Tags,
+				Run:            data.Run,
+				RunConfig:      data.RunConfig,
 				Update:         true,
 			})
 			break
@@ -119,24 +92,30 @@ func (s *LocalStore) Handle(payload *flux.Payload) bool {
 
 		// Hash in page path -> load files from src.jsgo.io json blob
 		if shaRegex.MatchString(location) {
-			resp, err := http.Get(fmt.Sprintf("%s://%s/%s.json", config.Protocol[config.Src], config.Host[config.Src], location))
+			body, err := s.app.Backend.Backend().Fetch(config.Src, location+".json")
 			if err != nil {
-				s.app.Fail(err)
-				return true
-			}
-			if resp.StatusCode != 200 {
-				s.app.Fail(fmt.Errorf("error %d loading source", resp.StatusCode))
+				s.app.Fail(models.NetworkSource, err)
 				return true
 			}
+			defer body.Close()
 			var sp models.SharePack
-			if err := json.NewDecoder(resp.Body).Decode(&sp); err != nil {
-				s.app.Fail(err)
+			if err := json.NewDecoder(body).Decode(&sp); err != nil {
+				s.app.Fail(models.NetworkSource, err)
 				return true
 			}
+			// the run configurations are in the source if the server doesn't unpack them
+			source, run := models.UnpackRunConfigs(sp.Source)
+			run = append(run, sp.Run...)
+			var selected string
+			if len(run) > 0 {
+				selected = run[0].Name
+			}
 			s.app.Dispatch(&actions.LoadSource{
-				Source: sp.Source,
-				Tags:   sp.Tags,
-				Update: true,
+				Source:    source,
+				Tags:      sp.Tags,
+				Run:       run,
+				RunConfig: selected,
+				Update:    true,
 			})
 			break
 		}
@@ -146,76 +125,15 @@ func (s *LocalStore) Handle(payload *flux.Payload) bool {
 
 	case *actions.UserChangedSplit:
 		if err := s.saveSplitSizes(action.Sizes); err != nil {
-			s.app.Fail(err)
-			return true
-		}
-	case *actions.UserChangedText, *actions.FormatCode:
-		payload.Wait(s.app.Editor)
-		if err := s.saveSource(); err != nil {
-			s.app.Fail(err)
-			return true
-		}
-	case *actions.BuildTags:
-		payload.Wait(s.app.Compile)
-		if err := s.saveSource(); err != nil {
-			s.app.Fail(err)
-			return true
-		}
-	case *actions.UserChangedFile:
-		payload.Wait(s.app.Editor)
-		if err := s.saveSource(); err != nil {
-			s.app.Fail(err)
+			s.app.Fail(models.StorageSource, err)
 			return true
 		}
-	case *actions.UserChangedPackage:
-		payload.Wait(s.app.Editor)
-		if err := s.saveSource(); err != nil {
-			s.app.Fail(err)
-			return true
-		}
-	case *actions.AddFile, *actions.DeleteFile:
-		payload.Wait(s.app.Source)
-		if err := s.saveSource(); err != nil {
-			s.app.Fail(err)
-			return true
-		}
-	case *actions.AddPackage, *actions.RemovePackage, *actions.DragDrop:
-		payload.Wait(s.app.Editor)
-		if err := s.saveSource(); err != nil {
-			s.app.Fail(err)
-			return true
-		}
-	case *actions.LoadSource:
-		if action.Save {
-			payload.Wait(s.app.Editor)
-			if err := s.saveSource(); err != nil {
-				s.app.Fail(err)
-				return true
-			}
-		}
 	}
 	return true
 }
 
-func (s *LocalStore) saveSource() error {
-	s.local.Delete("files") // delete old format file storage location
-	if err := s.local.Save("source", s.app.Source.Source()); err != nil {
-		return err
-	}
-	if err := s.local.Save("current-package", s.app.Editor.CurrentPackage()); err != nil {
-		return err
-	}
-	if err := s.local.Save("current-file", s.app.Editor.CurrentFile()); err != nil {
-		return err
-	}
-	if err := s.local.Save("build-tags", s.app.Compile.Tags()); err != nil {
-		return err
-	}
-	return nil
-}
-
 func (s *LocalStore) saveSplitSizes(sizes []float64) error {
-	return s.local.Save("split-sizes", sizes)
+	return s.app.Storage.Save("split-sizes", sizes)
 }
 
 var (
@@ -224,6 +142,7 @@ var (
 
 import (
 	"fmt"
+
 	"honnef.co/go/js/dom"
 )
 
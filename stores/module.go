@@ -0,0 +1,125 @@
+package stores
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dave/flux"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"golang.org/x/mod/modfile"
+)
+
+func NewModuleStore(app *App) *ModuleStore {
+	s := &ModuleStore{
+		app: app,
+	}
+	return s
+}
+
+// ModuleStore parses the go.mod file in the project. The requirements are used to pin the versions
+// of packages requested from the server.
+type ModuleStore struct {
+	app *App
+
+	module *models.Module
+	err    error
+}
+
+// Module returns the parsed go.mod file, or nil if the project has no go.mod or it can't be parsed.
+func (s *ModuleStore) Module() *models.Module {
+	return s.module
+}
+
+// Err returns the error from parsing go.mod
+func (s *ModuleStore) Err() error {
+	return s.err
+}
+
+// Version returns the version of the required module that provides package path, or an empty string
+// if path is not provided by a required module.
+func (s *ModuleStore) Version(path string) string {
+	if s.module == nil {
+		return ""
+	}
+	var module, version string
+	for _, r := range s.module.Require {
+		if (path == r.Path || strings.HasPrefix(path, r.Path+"/")) && len(r.Path) > len(module) {
+			module, version = r.Path, r.Version
+		}
+	}
+	return version
+}
+
+// Pinned returns path with the pinned version appended (e.g. path@v1.2.3). If path already has a
+// version or is not provided by a required module, it is returned unchanged.
+func (s *ModuleStore) Pinned(path string) string {
+	if strings.Contains(path, "@") {
+		return path
+	}
+	if version := s.Version(path); version != "" {
+		return path + "@" + version
+	}
+	return path
+}
+
+func (s *ModuleStore) Handle(payload *flux.Payload) bool {
+	switch payload.Action.(type) {
+	case *actions.LoadSource:
+		// an update is requested after loading, so the archives are not invalidated here
+		payload.Wait(s.app.Source)
+		if s.parse() {
+			payload.Notify()
+		}
+	case *actions.UserChangedText, *actions.AddFile, *actions.DeleteFile, *actions.RemovePackage,
+		*actions.DragDrop, *actions.RestoreSource:
+		payload.Wait(s.app.Source)
+		if s.parse() {
+			s.app.Dispatch(&actions.ModuleChanged{})
+			payload.Notify()
+		}
+	}
+	return true
+}
+
+// parse parses the go.mod file, and returns true if the module or the error has changed.
+func (s *ModuleStore) parse() bool {
+	module, err := s.read()
+	changed := !reflect.DeepEqual(module, s.module) || fmt.Sprint(err) != fmt.Sprint(s.err)
+	s.module, s.err = module, err
+	return changed
+}
+
+func (s *ModuleStore) read() (*models.Module, error) {
+	for _, path := range s.app.Source.Packages() {
+		contents, ok := s.app.Source.Files(path)["go.mod"]
+		if !ok {
+			continue
+		}
+		f, err := modfile.ParseLax("go.mod", []byte(contents), nil)
+		if err != nil {
+			return nil, err
+		}
+		m := &models.Module{
+			Package: path,
+		}
+		if f.Module != nil {
+			m.Path = f.Module.Mod.Path
+		}
+		if f.Go != nil {
+			m.Go = f.Go.Version
+		}
+		for _, r := range f.Require {
+			m.Require = append(m.Require, models.Requirement{
+				Path:     r.Mod.Path,
+				Version:  r.Mod.Version,
+				Indirect: r.Indirect,
+			})
+		}
+		sort.Slice(m.Require, func(i, j int) bool { return m.Require[i].Path < m.Require[j].Path })
+		return m, nil
+	}
+	return nil, nil
+}
@@ -55,6 +55,12 @@ func (s *PageStore) Handle(payload *flux.Payload) bool {
 	case *actions.MinifyToggleClick:
 		s.minify = !s.minify
 		payload.Notify()
+	case *actions.TestStart:
+		// test results are written to the console
+		if !s.console {
+			s.console = true
+			payload.Notify()
+		}
 	case *actions.ConsoleFirstWrite:
 		if s.autoOpen {
 			s.console = true
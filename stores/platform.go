@@ -0,0 +1,76 @@
+package stores
+
+import (
+	"net/url"
+
+	"github.com/dave/play/models"
+)
+
+// The stores reach the browser through these interfaces, so the app can be run with fakes in tests.
+// App.Init uses the browser implementations for any that aren't set. The compile server is reached
+// through backend.Backend (see BackendStore.Set).
+
+// Document is the page the app runs in
+type Document interface {
+	// Status returns the status message, and SetStatus changes it
+	Status() string
+	SetStatus(message string)
+	// Meta returns the content of the meta tag name, or "" if it doesn't exist
+	Meta(name string) string
+	// Debug writes to the developer console
+	Debug(message ...interface{})
+}
+
+// Storage persists data between page loads: local storage for the settings and the state of
+// workspaces, and IndexedDB for the source and archives.
+type Storage interface {
+	Find(key string, value interface{}) (found bool, err error)
+	Save(key string, value interface{}) error
+	Delete(key string) error
+	// Watch calls f when another tab changes a key. value is the JSON encoded value, and deleted is
+	// true if the key was removed.
+	Watch(f func(key, value string, deleted bool))
+	// Database opens an IndexedDB database, creating the object stores if they don't exist
+	Database(name string, version int, stores ...string) (Database, error)
+}
+
+// Database is an IndexedDB database (see the idb package). Records are keyed by strings, so they can
+// be listed by key prefix, and the values are strings or byte slices.
+type Database interface {
+	// Prefix returns the string records in store with a key starting with prefix
+	Prefix(store, prefix string) (map[string]string, error)
+	// Update puts and deletes string records in store in one transaction
+	Update(store string, put map[string]string, del []string) error
+	// PrefixBytes and UpdateBytes are Prefix and Update for byte slice records
+	PrefixBytes(store, prefix string) (map[string][]byte, error)
+	UpdateBytes(store string, put map[string][]byte, del []string) error
+	// DeletePrefix deletes the records in store with a key starting with prefix
+	DeletePrefix(store, prefix string) error
+}
+
+// Location is the address of the page
+type Location interface {
+	// URL is the URL of the page
+	URL() *url.URL
+	// Replace changes the path of the page without adding a history entry
+	Replace(path string)
+}
+
+// Runner runs compiled programs. The output of the program is written to the console, and the file
+// system calls are made to the virtual file system.
+type Runner interface {
+	// Run stops the running program and starts program
+	Run(program Program) error
+	// Stop stops the running program
+	Stop()
+}
+
+// Program is a compiled main package
+type Program struct {
+	Path  string
+	Index string            // template for the page the program runs in, or empty for a blank page
+	Deps  []models.Dep      // the JS of the dependencies, in load order (compiled by GopherJS)
+	Wasm  *models.Wasm      // the WebAssembly binary (compiled by the server), or nil
+	Args  []string          // os.Args, including the program name
+	Env   map[string]string // the environment
+}
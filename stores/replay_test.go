@@ -0,0 +1,495 @@
+package stores
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dave/jsgo/config"
+	"github.com/dave/jsgo/server/play/messages"
+	"github.com/dave/jsgo/server/servermsg"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores/backend"
+	"github.com/dave/services"
+	"github.com/dave/services/builder/buildermsg"
+	"github.com/dave/services/constor/constormsg"
+	"github.com/dave/services/deployer/deployermsg"
+	"github.com/dave/services/getter/gettermsg"
+	"github.com/gopherjs/gopherjs/compiler"
+)
+
+const closedNotUpdated = "websocket closed but archives not updated"
+
+func init() {
+	// retry quickly
+	retryDelay = time.Millisecond
+}
+
+// updateTranscript is an update of a project with no imports: the prelude and runtime archives are
+// sent, followed by the index.
+var updateTranscript = exchange{
+	Request: messages.Update{},
+	Responses: []services.Message{
+		servermsg.Queueing{Position: 2},
+		servermsg.Queueing{Done: true},
+		deployermsg.Archive{Path: "prelude", Hash: "p1", Standard: true},
+		deployermsg.Archive{Path: "runtime", Hash: "r1", Standard: true},
+		deployermsg.ArchiveIndex{
+			"prelude": {Hash: "p1"},
+			"runtime": {Hash: "r1"},
+		},
+	},
+}
+
+func TestReplayUpdate(t *testing.T) {
+	r := newReplay(t, packageHost(t, map[string]string{"prelude": "p1", "runtime": "r1"}), updateTranscript)
+	app := newTestApp(r.fake)
+	loadMain(app, true)
+
+	waitFor(t, app, "2 downloaded", func() bool { return app.Document.Status() == "2 downloaded" })
+	r.done()
+
+	var fresh bool
+	var cache map[string]string
+	var log []models.Error
+	read(app, func() {
+		fresh, cache, log = app.Archive.Fresh("main"), app.Archive.CacheStrings(), app.Error.Log()
+	})
+	if !fresh {
+		t.Fatal("archives not fresh")
+	}
+	if cache["prelude"] != "p1" || cache["runtime"] != "r1" {
+		t.Fatalf("unexpected cache %v", cache)
+	}
+	if len(log) > 0 {
+		t.Fatalf("unexpected errors %v", log)
+	}
+}
+
+func TestReplayDroppedArchive(t *testing.T) {
+	// the runtime archive is missing from the package host
+	r := newReplay(t, packageHost(t, map[string]string{"prelude": "p1"}), updateTranscript)
+	app := newTestApp(r.fake)
+	loadMain(app, true)
+
+	waitForError(t, app, closedNotUpdated)
+	waitForError(t, app, "error 404 fetching runtime.r1")
+	r.done()
+
+	var fresh bool
+	read(app, func() { fresh = app.Archive.Fresh("main") })
+	if fresh {
+		t.Fatal("archives fresh after runtime was dropped")
+	}
+}
+
+func TestReplayEarlyClose(t *testing.T) {
+	// the connection closes before the archives are sent
+	r := newReplay(t, packageHost(t, nil), exchange{
+		Request:   messages.Update{},
+		Responses: []services.Message{servermsg.Queueing{Position: 1}},
+	})
+	app := newTestApp(r.fake)
+	loadMain(app, true)
+
+	waitForError(t, app, closedNotUpdated)
+	r.done()
+
+	var fresh bool
+	read(app, func() { fresh = app.Archive.Fresh("main") })
+	if fresh {
+		t.Fatal("archives fresh after early close")
+	}
+}
+
+func TestReplayServerError(t *testing.T) {
+	r := newReplay(t, packageHost(t, nil), exchange{
+		Request:   messages.Update{},
+		Responses: []services.Message{servermsg.Error{Message: "server is too busy"}},
+	})
+	app := newTestApp(r.fake)
+	loadMain(app, true)
+
+	waitForError(t, app, "server is too busy")
+	waitForError(t, app, closedNotUpdated)
+	r.done()
+
+	var busy bool
+	read(app, func() { busy = app.Connection.Busy() })
+	if busy {
+		t.Fatal("operation still in progress")
+	}
+}
+
+func TestReplayConnectionError(t *testing.T) {
+	// the connection fails every time it's retried
+	lost := exchange{
+		Request:   messages.Update{},
+		Responses: []services.Message{servermsg.Queueing{Position: 1}},
+		Err:       errors.New("connection reset"),
+	}
+	r := newReplay(t, packageHost(t, nil), lost, lost, lost, lost, lost)
+	app := newTestApp(r.fake)
+	loadMain(app, true)
+
+	waitForError(t, app, "connection reset")
+	r.done()
+
+	var state models.ConnectionState
+	var busy bool
+	read(app, func() { state, busy = app.Connection.State(), app.Connection.Busy() })
+	if state != models.ConnectionFailed {
+		t.Fatalf("connection state %q", state)
+	}
+	if busy {
+		t.Fatal("operation still in progress")
+	}
+}
+
+func TestReplayReconnect(t *testing.T) {
+	// the connection is lost after the prelude is sent, so the update is sent again when it's
+	// reopened, and only the runtime is sent
+	r := newReplay(t, packageHost(t, map[string]string{"prelude": "p1", "runtime": "r1"}),
+		exchange{
+			Request: messages.Update{},
+			Responses: []services.Message{
+				deployermsg.Archive{Path: "prelude", Hash: "p1", Standard: true},
+			},
+			Err: errors.New("connection reset"),
+		},
+		exchange{
+			Request: messages.Update{},
+			Responses: []services.Message{
+				deployermsg.Archive{Path: "runtime", Hash: "r1", Standard: true},
+				deployermsg.ArchiveIndex{
+					"prelude": {Hash: "p1"},
+					"runtime": {Hash: "r1"},
+				},
+			},
+		},
+	)
+	app := newTestApp(r.fake)
+	loadMain(app, true)
+
+	waitFor(t, app, "archives fresh", func() bool { return app.Archive.Fresh("main") })
+	waitFor(t, app, "update done", func() bool { return !app.Connection.Busy() })
+	r.done()
+
+	if m := r.received(1).(messages.Update); m.Cache["prelude"] != "p1" {
+		t.Fatalf("retried update sent with cache %v", m.Cache)
+	}
+	if log := errorLog(app); len(log) > 0 {
+		t.Fatalf("unexpected errors %v", log)
+	}
+}
+
+func TestReplayFetchRetry(t *testing.T) {
+	// the package host fails the first time the runtime is fetched
+	r := newReplay(t, packageHost(t, map[string]string{"prelude": "p1", "runtime": "r1"}), updateTranscript)
+	var once sync.Once
+	r.fake.FetchError = func(host, name string) error {
+		var err error
+		if name == "runtime.r1.js" {
+			once.Do(func() { err = &backend.StatusError{Code: 503, Name: name} })
+		}
+		return err
+	}
+	app := newTestApp(r.fake)
+	loadMain(app, true)
+
+	waitFor(t, app, "2 downloaded", func() bool { return app.Document.Status() == "2 downloaded" })
+	waitFor(t, app, "archives fresh", func() bool { return app.Archive.Fresh("main") })
+	r.done()
+
+	if log := errorLog(app); len(log) > 0 {
+		t.Fatalf("unexpected errors %v", log)
+	}
+}
+
+func TestConcurrentShare(t *testing.T) {
+	// the project is shared while the update is in progress on the same connection
+	release := make(chan struct{})
+	var dials int32
+	fake := &backend.Fake{
+		Files: map[string]map[string][]byte{config.Pkg: packageHost(t, map[string]string{"prelude": "p1", "runtime": "r1"})},
+		Serve: func(message services.Message, send func(services.Message)) error {
+			switch message.(type) {
+			case messages.Update:
+				<-release
+				for _, m := range updateTranscript.Responses {
+					send(m)
+				}
+			case messages.Share:
+				send(messages.ShareComplete{Hash: "0123456789abcdef0123456789abcdef01234567"})
+			}
+			return nil
+		},
+	}
+	app := newTestApp(&countDials{Fake: fake, dials: &dials})
+	loadMain(app, true)
+	app.Dispatch(&actions.ShareStart{})
+
+	waitFor(t, app, "shared", func() bool { return len(app.Location.(*fakeLocation).Replaced()) > 0 })
+	var stoppable bool
+	read(app, func() { stoppable = app.Connection.Stoppable() })
+	if !stoppable {
+		t.Fatal("update not in progress")
+	}
+	close(release)
+	waitFor(t, app, "archives fresh", func() bool { return app.Archive.Fresh("main") })
+	waitFor(t, app, "update done", func() bool { return !app.Connection.Busy() })
+
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Fatalf("%d connections dialed", n)
+	}
+	if log := errorLog(app); len(log) > 0 {
+		t.Fatalf("unexpected errors %v", log)
+	}
+}
+
+func TestReplayInitialise(t *testing.T) {
+	source := map[string]map[string]string{"github.com/a/b": {"b.go": testFile}}
+	r := newReplay(t, packageHost(t, map[string]string{"prelude": "p1", "runtime": "r1"}), exchange{
+		Request: messages.Initialise{},
+		Responses: []services.Message{
+			servermsg.Queueing{Position: 1},
+			gettermsg.Downloading{Message: "github.com/a/b"},
+			messages.GetComplete{Source: source},
+			deployermsg.Archive{Path: "prelude", Hash: "p1", Standard: true},
+			deployermsg.Archive{Path: "runtime", Hash: "r1", Standard: true},
+			deployermsg.ArchiveIndex{
+				"prelude": {Hash: "p1"},
+				"runtime": {Hash: "r1"},
+			},
+		},
+	})
+	app := newTestApp(r.fake)
+	app.Dispatch(&actions.RequestStart{Type: models.InitialiseRequest, Path: "github.com/a/b"})
+
+	waitFor(t, app, "2 downloaded", func() bool { return app.Document.Status() == "2 downloaded" })
+	r.done()
+
+	if m := r.received(0).(messages.Initialise); m.Path != "github.com/a/b" {
+		t.Fatalf("initialise sent with path %q", m.Path)
+	}
+	var got string
+	var fresh bool
+	read(app, func() {
+		got, fresh = app.Source.Files("github.com/a/b")["b.go"], app.Archive.Fresh("github.com/a/b")
+	})
+	if got != testFile {
+		t.Fatalf("unexpected source %q", got)
+	}
+	if !fresh {
+		t.Fatal("archives not fresh")
+	}
+	// the project was loaded from the page path, so it isn't replaced until it's edited
+	if got := app.Location.(*fakeLocation).Replaced(); len(got) > 0 {
+		t.Fatalf("page path replaced with %v", got)
+	}
+}
+
+func TestReplayShare(t *testing.T) {
+	r := newReplay(t, packageHost(t, nil), exchange{
+		Request: messages.Share{},
+		Responses: []services.Message{
+			constormsg.Storing{Finished: 1},
+			constormsg.Storing{Done: true},
+			messages.ShareComplete{Hash: "0123456789abcdef0123456789abcdef01234567"},
+		},
+	})
+	app := newTestApp(r.fake)
+	loadMain(app, false)
+	app.Dispatch(&actions.ShareStart{})
+
+	waitFor(t, app, "shared", func() bool { return app.Document.Status() == "shared" })
+	r.done()
+
+	if m := r.received(0).(messages.Share); m.Source["main"]["main.go"] != testFile {
+		t.Fatalf("share sent with source %v", m.Source)
+	}
+	got := app.Location.(*fakeLocation).Replaced()
+	if len(got) != 1 || got[0] != "/0123456789abcdef0123456789abcdef01234567" {
+		t.Fatalf("page path replaced with %v", got)
+	}
+}
+
+func TestReplayDeploy(t *testing.T) {
+	r := newReplay(t, packageHost(t, nil), exchange{
+		Request: messages.Deploy{},
+		Responses: []services.Message{
+			servermsg.Queueing{Position: 1},
+			gettermsg.Downloading{Message: "fmt"},
+			buildermsg.Building{Message: "main"},
+			constormsg.Storing{Done: true},
+			messages.DeployComplete{Main: "m1", Index: "i1"},
+		},
+	})
+	app := newTestApp(r.fake)
+	loadMain(app, false)
+	app.Dispatch(&actions.DeployStart{})
+
+	waitFor(t, app, "deployed", func() bool { return app.Document.Status() == "deployed" })
+	r.done()
+
+	if m := r.received(0).(messages.Deploy); m.Main != "main" {
+		t.Fatalf("deploy sent with main %q", m.Main)
+	}
+	var loader, index string
+	read(app, func() { loader, index = app.Deploy.LoaderJs(), app.Deploy.Index() })
+	if want := r.fake.URL(config.Pkg, "main.m1.js"); loader != want {
+		t.Fatalf("loader is %q, want %q", loader, want)
+	}
+	if want := r.fake.URL(config.Index, "i1"); index != want {
+		t.Fatalf("index is %q, want %q", index, want)
+	}
+}
+
+// exchange is a recorded request to the server, and the responses to it. The operation is done after
+// the responses are sent.
+type exchange struct {
+	Request   services.Message // the expected message (only the type is compared)
+	Responses []services.Message
+	Err       error // if set, the connection is lost with Err after the responses are sent
+}
+
+// replay is a fake server that replays a transcript of exchanges in order
+type replay struct {
+	t         *testing.T
+	fake      *backend.Fake
+	exchanges []exchange
+
+	sync.Mutex
+	messages []services.Message // messages received
+}
+
+func newReplay(t *testing.T, host map[string][]byte, exchanges ...exchange) *replay {
+	r := &replay{t: t, exchanges: exchanges}
+	r.fake = &backend.Fake{
+		Serve: r.serve,
+		Files: map[string]map[string][]byte{config.Pkg: host},
+	}
+	return r
+}
+
+func (r *replay) serve(message services.Message, send func(services.Message)) error {
+	r.Lock()
+	i := len(r.messages)
+	r.messages = append(r.messages, message)
+	r.Unlock()
+	if i >= len(r.exchanges) {
+		r.t.Errorf("unexpected message %T", message)
+		return fmt.Errorf("unexpected message %T", message)
+	}
+	e := r.exchanges[i]
+	if fmt.Sprintf("%T", message) != fmt.Sprintf("%T", e.Request) {
+		r.t.Errorf("exchange %d: received %T, expected %T", i, message, e.Request)
+		return fmt.Errorf("unexpected message %T", message)
+	}
+	for _, response := range e.Responses {
+		send(response)
+	}
+	return e.Err
+}
+
+// received returns message i received by the server
+func (r *replay) received(i int) services.Message {
+	r.Lock()
+	defer r.Unlock()
+	if i >= len(r.messages) {
+		r.t.Fatalf("message %d not received", i)
+	}
+	return r.messages[i]
+}
+
+// done checks that all the exchanges have been made
+func (r *replay) done() {
+	r.Lock()
+	defer r.Unlock()
+	if len(r.messages) != len(r.exchanges) {
+		r.t.Fatalf("%d of %d exchanges made", len(r.messages), len(r.exchanges))
+	}
+}
+
+// countDials counts the connections dialed
+type countDials struct {
+	*backend.Fake
+	dials *int32
+}
+
+func (c *countDials) Dial(handler backend.Handler) (backend.Conn, error) {
+	atomic.AddInt32(c.dials, 1)
+	return c.Fake.Dial(handler)
+}
+
+// packageHost returns the files of the package host for the archives (path -> hash). The prelude
+// only has a JS file.
+func packageHost(t *testing.T, archives map[string]string) map[string][]byte {
+	files := map[string][]byte{}
+	for path, hash := range archives {
+		files[fmt.Sprintf("%s.%s.js", path, hash)] = []byte("// " + path)
+		if path == "prelude" {
+			continue
+		}
+		buf := &bytes.Buffer{}
+		if err := gob.NewEncoder(buf).Encode(&compiler.Archive{ImportPath: path, Name: path}); err != nil {
+			t.Fatal(err)
+		}
+		files[fmt.Sprintf("%s.%s.ax", path, hash)] = buf.Bytes()
+	}
+	return files
+}
+
+// loadMain loads a main package with no imports
+func loadMain(app *App, update bool) {
+	<-app.Dispatch(&actions.LoadSource{
+		Source:         map[string]map[string]string{"main": {"main.go": testFile}},
+		CurrentPackage: "main",
+		CurrentFile:    "main.go",
+		Update:         update,
+	})
+}
+
+// waitFor waits for condition to be true. The stores handle the messages from the server in the
+// background, so condition is run between actions (see read).
+func waitFor(t *testing.T, app *App, description string, condition func() bool) {
+	timeout := time.After(time.Second * 5)
+	for {
+		var done bool
+		read(app, func() { done = condition() })
+		if done {
+			return
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", description)
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+}
+
+// errorLog returns the errors that have been reported
+func errorLog(app *App) []models.Error {
+	var log []models.Error
+	read(app, func() { log = app.Error.Log() })
+	return log
+}
+
+// waitForError waits for an error containing message to be reported
+func waitForError(t *testing.T, app *App, message string) {
+	waitFor(t, app, fmt.Sprintf("error %q", message), func() bool {
+		for _, e := range app.Error.Notifications() {
+			if e.Severity == models.ErrorSeverity && strings.Contains(e.Message, message) {
+				return true
+			}
+		}
+		return false
+	})
+}
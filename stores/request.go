@@ -6,6 +6,7 @@ import (
 	"github.com/dave/jsgo/server/servermsg"
 	"github.com/dave/play/actions"
 	"github.com/dave/play/models"
+	"github.com/dave/play/stores/builderjs"
 	"github.com/dave/services"
 	"github.com/dave/services/getter/gettermsg"
 )
@@ -26,12 +27,13 @@ func (s *RequestStore) Handle(payload *flux.Payload) bool {
 	case *actions.RequestStart:
 		s.app.Log("downloading")
 		s.app.Dispatch(&actions.Dial{
-			Url:  defaultUrl(),
-			Open: func() flux.ActionInterface { return &actions.RequestOpen{RequestStart: action} },
+			Open: func(id int) flux.ActionInterface { return &actions.RequestOpen{RequestStart: action, ID: id} },
 			Message: func(m interface{}) flux.ActionInterface {
 				return &actions.RequestMessage{RequestStart: action, Message: m}
 			},
 			Close: func() flux.ActionInterface { return &actions.RequestClose{RequestStart: action} },
+			// the requests for the archives are part of running the project
+			Stoppable: true,
 		})
 		payload.Notify()
 	case *actions.RequestOpen:
@@ -42,8 +44,21 @@ func (s *RequestStore) Handle(payload *flux.Payload) bool {
 				Path: action.Path,
 			}
 		case models.UpdateRequest:
+			// the cache is sent, so a retried request only gets the archives that are missing
+			payload.Wait(s.app.Archive)
+			source := s.app.Source.Source()
+			if action.Test {
+				// include the generated test main package so the archives for the test
+				// dependencies are sent
+				var err error
+				source, err = builderjs.TestSource(action.Path, source, s.app.Compile.Tags())
+				if err != nil {
+					s.app.Fail(models.CompileSource, err)
+					return true
+				}
+			}
 			message = messages.Update{
-				Source: s.app.Source.Source(),
+				Source: source,
 				Cache:  s.app.Archive.CacheStrings(),
 				Minify: s.app.Page.Minify(),
 				Tags:   s.app.Compile.Tags(),
@@ -55,6 +70,7 @@ func (s *RequestStore) Handle(payload *flux.Payload) bool {
 			}
 		}
 		s.app.Dispatch(&actions.Send{
+			ID:      action.ID,
 			Message: message,
 		})
 	case *actions.RequestMessage:
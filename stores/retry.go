@@ -0,0 +1,14 @@
+package stores
+
+import "time"
+
+// maxRetries is the number of times a failed request or connection is retried
+const maxRetries = 4
+
+// retryDelay is the delay before the first retry. It's doubled for each retry after that.
+var retryDelay = time.Millisecond * 500
+
+// backoff returns the delay before retry number attempt (starting at 1)
+func backoff(attempt int) time.Duration {
+	return retryDelay << uint(attempt-1)
+}
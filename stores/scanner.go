@@ -137,26 +137,13 @@ func (s *ScannerStore) Handle(payload *flux.Payload) bool {
 	switch action := payload.Action.(type) {
 	case *actions.BuildTags:
 		payload.Wait(s.app.Compile)
-
-		s.imports = map[string]map[string][]string{}
-		s.names = map[string]string{}
-
-		var changed bool
-		for path, files := range s.app.Source.Source() {
-			for name, contents := range files {
-				if s.refresh(path, name, contents) {
-					changed = true
-				}
-			}
-		}
-
-		if s.checkForClash() {
-			changed = true
-		}
-
-		if changed {
+		if s.refreshAll() {
 			payload.Notify()
 		}
+	case *actions.RestoreSource:
+		payload.Wait(s.app.Source)
+		s.refreshAll()
+		payload.Notify()
 
 	case *actions.DeleteFile:
 		delete(s.imports[s.app.Editor.CurrentPackage()], action.Name)
@@ -226,6 +213,27 @@ func (s *ScannerStore) Handle(payload *flux.Payload) bool {
 	return true
 }
 
+// refreshAll scans all the files in the source, and returns true if anything changed.
+func (s *ScannerStore) refreshAll() bool {
+	s.imports = map[string]map[string][]string{}
+	s.names = map[string]string{}
+
+	var changed bool
+	for path, files := range s.app.Source.Source() {
+		for name, contents := range files {
+			if s.refresh(path, name, contents) {
+				changed = true
+			}
+		}
+	}
+
+	if s.checkForClash() {
+		changed = true
+	}
+
+	return changed
+}
+
 func (s *ScannerStore) checkForClash() bool {
 
 	clashes := map[string]map[string]bool{}
@@ -266,6 +274,11 @@ func (s *ScannerStore) checkForClash() bool {
 
 func (s *ScannerStore) refresh(path, filename, contents string) bool {
 
+	if strings.HasSuffix(filename, "_test.go") {
+		// test files are scanned when the tests are compiled
+		return false
+	}
+
 	include, err := includer.New(map[string]string{filename: contents}, s.app.Compile.Tags()).Include(filename)
 	if err != nil {
 		// ignore errors (we never want to throw an error while we're scanning the source)
@@ -6,8 +6,8 @@ import (
 	"github.com/dave/flux"
 	"github.com/dave/jsgo/server/play/messages"
 	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
 	"github.com/dave/services/constor/constormsg"
-	"github.com/gopherjs/gopherjs/js"
 )
 
 func NewShareStore(app *App) *ShareStore {
@@ -24,20 +24,27 @@ type ShareStore struct {
 func (s *ShareStore) Handle(payload *flux.Payload) bool {
 	switch action := payload.Action.(type) {
 	case *actions.ShareStart:
+		// files loaded with the reserved name would be taken for the run configurations
+		for path, files := range s.app.Source.Source() {
+			if _, ok := files[models.RunConfigFile]; ok {
+				s.app.Fail(models.ProjectSource, fmt.Errorf("%s in %s can't be shared: the name is reserved for run configurations", models.RunConfigFile, path))
+				return true
+			}
+		}
 		s.app.Log("sharing")
 		s.app.Dispatch(&actions.Dial{
-			Url:     defaultUrl(),
-			Open:    func() flux.ActionInterface { return &actions.ShareOpen{} },
+			Open:    func(id int) flux.ActionInterface { return &actions.ShareOpen{ID: id} },
 			Message: func(m interface{}) flux.ActionInterface { return &actions.ShareMessage{Message: m} },
 			Close:   func() flux.ActionInterface { return &actions.ShareClose{} },
 		})
 		payload.Notify()
 	case *actions.ShareOpen:
 		message := messages.Share{
-			Source: s.app.Source.Source(),
+			Source: models.PackRunConfigs(s.app.Source.Source(), s.app.Compile.RunConfigs()),
 			Tags:   s.app.Compile.Tags(),
 		}
 		s.app.Dispatch(&actions.Send{
+			ID:      action.ID,
 			Message: message,
 		})
 	case *actions.ShareMessage:
@@ -45,7 +52,7 @@ func (s *ShareStore) Handle(payload *flux.Payload) bool {
 		case constormsg.Storing:
 			s.app.Log("storing")
 		case messages.ShareComplete:
-			js.Global.Get("history").Call("replaceState", js.M{}, "", fmt.Sprintf("/%s", message.Hash))
+			s.app.Location.Replace(fmt.Sprintf("/%s", message.Hash))
 			s.app.LogHide("shared")
 		}
 	case *actions.ShareClose:
@@ -22,6 +22,7 @@ import (
 	"github.com/dave/flux"
 	"github.com/dave/jsgo/config"
 	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
 	"github.com/dave/saver"
 )
 
@@ -123,12 +124,12 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 		if len(a.Files) == 1 && strings.HasSuffix(a.Files[0].Name(), ".zip") {
 			b, err := ioutil.ReadAll(a.Files[0].Reader())
 			if err != nil {
-				s.app.Fail(err)
+				s.app.Fail(models.ProjectSource, err)
 				return true
 			}
 			zr, err := zip.NewReader(bytes.NewReader(b), int64(a.Files[0].Len()))
 			if err != nil {
-				s.app.Fail(err)
+				s.app.Fail(models.ProjectSource, err)
 				return true
 			}
 			for _, file := range zr.File {
@@ -145,13 +146,13 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 				}
 				fr, err := file.Open()
 				if err != nil {
-					s.app.Fail(err)
+					s.app.Fail(models.ProjectSource, err)
 					return true
 				}
 				b, err := ioutil.ReadAll(fr)
 				if err != nil {
 					fr.Close()
-					s.app.Fail(err)
+					s.app.Fail(models.ProjectSource, err)
 					return true
 				}
 				fr.Close()
@@ -174,7 +175,7 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 				}
 				b, err := ioutil.ReadAll(f.Reader())
 				if err != nil {
-					s.app.Fail(err)
+					s.app.Fail(models.ProjectSource, err)
 					return true
 				}
 				if packages[path] == nil {
@@ -219,11 +220,11 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 			for name, contents := range files {
 				w, err := zw.Create(name)
 				if err != nil {
-					s.app.Fail(err)
+					s.app.Fail(models.ProjectSource, err)
 					return true
 				}
 				if _, err := io.Copy(w, strings.NewReader(contents)); err != nil {
-					s.app.Fail(err)
+					s.app.Fail(models.ProjectSource, err)
 					return true
 				}
 			}
@@ -232,11 +233,11 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 				for name, contents := range files {
 					w, err := zw.Create(filepath.Join(path, name))
 					if err != nil {
-						s.app.Fail(err)
+						s.app.Fail(models.ProjectSource, err)
 						return true
 					}
 					if _, err := io.Copy(w, strings.NewReader(contents)); err != nil {
-						s.app.Fail(err)
+						s.app.Fail(models.ProjectSource, err)
 						return true
 					}
 				}
@@ -248,11 +249,11 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 		p := s.app.Editor.CurrentPackage()
 		f := s.app.Editor.CurrentFile()
 		if p == "" {
-			s.app.Fail(errors.New("no package selected"))
+			s.app.Fail(models.ProjectSource, errors.New("no package selected"))
 			return true
 		}
 		if f == "" {
-			s.app.Fail(errors.New("no file selected"))
+			s.app.Fail(models.ProjectSource, errors.New("no file selected"))
 			return true
 		}
 		if s.source[p] == nil {
@@ -265,7 +266,7 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 	case *actions.AddFile:
 		p := s.app.Editor.CurrentPackage()
 		if p == "" {
-			s.app.Fail(errors.New("no package selected"))
+			s.app.Fail(models.ProjectSource, errors.New("no package selected"))
 			return true
 		}
 		if s.source[p] == nil {
@@ -273,6 +274,8 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 		}
 		if s.app.Scanner.Name(p) != "" && strings.HasSuffix(a.Name, ".go") {
 			s.source[p][a.Name] = "package " + s.app.Scanner.Name(p) + "\n\n"
+		} else if a.Name == "go.mod" {
+			s.source[p][a.Name] = "module " + p + "\n"
 		} else {
 			s.source[p][a.Name] = ""
 		}
@@ -285,22 +288,22 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 	case *actions.DeleteFile:
 		p := s.app.Editor.CurrentPackage()
 		if p == "" {
-			s.app.Fail(errors.New("no package selected"))
+			s.app.Fail(models.ProjectSource, errors.New("no package selected"))
 			return true
 		}
 		if !s.HasPackage(p) {
-			s.app.Fail(fmt.Errorf("package %s not found", p))
+			s.app.Fail(models.ProjectSource, fmt.Errorf("package %s not found", p))
 			return true
 		}
 		if !s.HasFile(p, a.Name) {
-			s.app.Fail(fmt.Errorf("%s not found", a.Name))
+			s.app.Fail(models.ProjectSource, fmt.Errorf("%s not found", a.Name))
 			return true
 		}
 		delete(s.source[p], a.Name)
 		payload.Notify()
 	case *actions.RemovePackage:
 		if !s.HasPackage(a.Path) {
-			s.app.Fail(fmt.Errorf("%s not found", a.Path))
+			s.app.Fail(models.ProjectSource, fmt.Errorf("%s not found", a.Path))
 			return true
 		}
 		delete(s.source, a.Path)
@@ -312,13 +315,16 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 			}
 		}
 		payload.Notify()
+	case *actions.RestoreSource:
+		s.source = a.Source
+		payload.Notify()
 	case *actions.FormatCode:
 		p := s.app.Editor.CurrentPackage()
 		f := s.app.Editor.CurrentFile()
 		if strings.HasSuffix(f, ".go") {
 			b, err := format.Source([]byte(s.Contents(p, f)))
 			if err != nil {
-				s.app.Fail(err)
+				s.app.Fail(models.ProjectSource, err)
 				return true
 			}
 			s.source[p][f] = string(b)
@@ -332,10 +338,22 @@ func (s *SourceStore) Handle(payload *flux.Payload) bool {
 }
 
 func isValidFile(name string) bool {
+	if name == "go.mod" || name == "go.sum" {
+		return true
+	}
 	for _, ext := range config.ValidExtensions {
 		if strings.HasSuffix(name, ext) {
 			return true
 		}
 	}
+	for _, ext := range dataExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
 	return false
 }
+
+// dataExtensions are the files that may be dropped into the project as data for the program, which
+// reads them from the virtual file system (see FilesystemStore).
+var dataExtensions = []string{".json", ".txt", ".csv"}
@@ -0,0 +1,125 @@
+package stores
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dave/play/models"
+	"github.com/neelance/sourcemap"
+)
+
+// sourceMaps rewrites positions in the JS of the running program (in stack traces printed to the
+// console) to the positions in the Go source.
+type sourceMaps struct {
+	app  *App
+	raw  map[string][]byte               // package path -> source map JSON
+	maps map[string][]*sourcemap.Mapping // package path -> decoded mappings, sorted by position
+}
+
+func newSourceMaps(app *App, deps []models.Dep) *sourceMaps {
+	m := &sourceMaps{
+		app:  app,
+		raw:  map[string][]byte{},
+		maps: map[string][]*sourcemap.Mapping{},
+	}
+	for _, dep := range deps {
+		if dep.SourceMap != nil {
+			m.raw[dep.Path] = dep.SourceMap
+		}
+	}
+	return m
+}
+
+// scriptURL is the name of the script for package path, which is used in stack traces.
+func scriptURL(path string) string {
+	return path + ".js"
+}
+
+// jsPositionRegex matches positions in stack traces (e.g. "github.com/foo/bar.js:12:34")
+var jsPositionRegex = regexp.MustCompile(`([^\s()@]+)\.js:(\d+):(\d+)`)
+
+// HTML escapes text, and replaces the positions in the JS with links to the Go source.
+func (m *sourceMaps) HTML(text string) string {
+	buf := &bytes.Buffer{}
+	var last int
+	for _, match := range jsPositionRegex.FindAllStringSubmatchIndex(text, -1) {
+		name := text[match[2]:match[3]]
+		line, _ := strconv.Atoi(text[match[4]:match[5]])
+		column, _ := strconv.Atoi(text[match[6]:match[7]])
+		path, file, origLine, origColumn, ok := m.lookup(name, line, column)
+		if !ok {
+			continue
+		}
+		buf.WriteString(html.EscapeString(text[last:match[0]]))
+		fmt.Fprintf(buf,
+			`<a href="" class="source-link" data-path="%s" data-file="%s" data-line="%d" data-column="%d">%s:%d</a>`,
+			html.EscapeString(path), html.EscapeString(file), origLine, origColumn, html.EscapeString(path+"/"+file), origLine,
+		)
+		last = match[1]
+	}
+	buf.WriteString(html.EscapeString(text[last:]))
+	return buf.String()
+}
+
+// lookup returns the Go position of a position in the script name. name may have a prefix (e.g. if
+// it was resolved to a URL by the browser), so the longest package path that it ends with is used.
+func (m *sourceMaps) lookup(name string, line, column int) (path, file string, origLine, origColumn int, ok bool) {
+	for p := range m.raw {
+		if (name == p || strings.HasSuffix(name, "/"+p)) && len(p) > len(path) {
+			path = p
+		}
+	}
+	if path == "" {
+		return "", "", 0, 0, false
+	}
+	mappings := m.mappings(path)
+
+	// stack trace columns are 1-based, source map columns are 0-based
+	column--
+	i := sort.Search(len(mappings), func(i int) bool {
+		mp := mappings[i]
+		return mp.GeneratedLine > line || (mp.GeneratedLine == line && mp.GeneratedColumn > column)
+	})
+	if i == 0 {
+		return "", "", 0, 0, false
+	}
+	mp := mappings[i-1]
+	if mp.GeneratedLine != line || mp.OriginalFile == "" {
+		return "", "", 0, 0, false
+	}
+	slash := strings.LastIndex(mp.OriginalFile, "/")
+	path, file = mp.OriginalFile[:slash], mp.OriginalFile[slash+1:]
+	if !m.app.Source.HasFile(path, file) {
+		// external test files are compiled as path + "_test", but live in path
+		path = strings.TrimSuffix(path, "_test")
+		if !m.app.Source.HasFile(path, file) {
+			return "", "", 0, 0, false
+		}
+	}
+	return path, file, mp.OriginalLine, mp.OriginalColumn, true
+}
+
+// mappings decodes the source map of package path the first time it's needed
+func (m *sourceMaps) mappings(path string) []*sourcemap.Mapping {
+	if mappings, ok := m.maps[path]; ok {
+		return mappings
+	}
+	var mappings []*sourcemap.Mapping
+	if sm, err := sourcemap.ReadFrom(bytes.NewReader(m.raw[path])); err == nil {
+		mappings = sm.DecodedMappings()
+		sort.SliceStable(mappings, func(i, j int) bool {
+			a, b := mappings[i], mappings[j]
+			if a.GeneratedLine != b.GeneratedLine {
+				return a.GeneratedLine < b.GeneratedLine
+			}
+			return a.GeneratedColumn < b.GeneratedColumn
+		})
+	}
+	m.maps[path] = mappings
+	return mappings
+}
@@ -0,0 +1,166 @@
+package stores
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/dave/flux"
+	"github.com/dave/play/actions"
+)
+
+// undoLimit is the maximum number of changes that can be undone
+const undoLimit = 100
+
+// typingDelay is the time after the first text change of a group within which further changes to
+// the same file are undone together, so continuous typing is undone a little at a time
+const typingDelay = time.Second
+
+func NewUndoStore(app *App) *UndoStore {
+	s := &UndoStore{
+		app: app,
+	}
+	return s
+}
+
+// UndoStore records the changes to the project so they can be undone. The whole project is
+// snapshotted after each change, so structural changes (e.g. removing a package) can be undone as
+// well as text changes in any file.
+type UndoStore struct {
+	app *App
+
+	current    snapshot
+	undo, redo []snapshot
+
+	// the file of the last text change and the time of the first change in its group, so
+	// consecutive changes can be merged
+	typed   string
+	typedAt time.Time
+}
+
+type snapshot struct {
+	source         map[string]map[string]string
+	currentPackage string
+	currentFile    string
+}
+
+func (s *UndoStore) CanUndo() bool {
+	return len(s.undo) > 0
+}
+
+func (s *UndoStore) CanRedo() bool {
+	return len(s.redo) > 0
+}
+
+func (s *UndoStore) Handle(payload *flux.Payload) bool {
+	switch a := payload.Action.(type) {
+	case *actions.LoadSource:
+		payload.Wait(s.app.Editor)
+		if a.Save {
+			// loading a package with Load package can be undone
+			s.record("")
+		} else {
+			// the initial source can't be undone
+			s.current = s.snapshot()
+			s.undo, s.redo = nil, nil
+		}
+		payload.Notify()
+	case *actions.UserChangedText:
+		payload.Wait(s.app.Source)
+		payload.Wait(s.app.Editor)
+		if a.Changed {
+			s.record(s.app.Editor.CurrentPackage() + "/" + s.app.Editor.CurrentFile())
+			payload.Notify()
+		}
+	case *actions.AddFile, *actions.DeleteFile, *actions.AddPackage, *actions.RemovePackage,
+		*actions.DragDrop, *actions.FormatCode:
+		payload.Wait(s.app.Source)
+		payload.Wait(s.app.Editor)
+		s.record("")
+		payload.Notify()
+	case *actions.RestoreSource:
+		if a.Reset {
+			payload.Wait(s.app.Editor)
+			s.current = s.snapshot()
+			s.undo, s.redo = nil, nil
+			s.typed = ""
+			payload.Notify()
+		}
+	case *actions.Undo:
+		if len(s.undo) == 0 {
+			return true
+		}
+		previous := s.undo[len(s.undo)-1]
+		s.undo = s.undo[:len(s.undo)-1]
+		s.redo = append(s.redo, s.current)
+		// show the file that was changed
+		s.restore(previous, s.current)
+		payload.Notify()
+	case *actions.Redo:
+		if len(s.redo) == 0 {
+			return true
+		}
+		next := s.redo[len(s.redo)-1]
+		s.redo = s.redo[:len(s.redo)-1]
+		s.undo = append(s.undo, s.current)
+		s.restore(next, next)
+		payload.Notify()
+	}
+	return true
+}
+
+// record adds the previous snapshot to the undo stack. If typed is not empty, the change is a text
+// change in that file, and is merged with the previous change if it was in the same file and the
+// group started within typingDelay.
+func (s *UndoStore) record(typed string) {
+	next := s.snapshot()
+	if reflect.DeepEqual(next.source, s.current.source) {
+		// nothing changed (e.g. formatting code that was already formatted)
+		s.current = next
+		return
+	}
+	merge := typed != "" && typed == s.typed && time.Since(s.typedAt) < typingDelay
+	if !merge {
+		s.undo = append(s.undo, s.current)
+		if len(s.undo) > undoLimit {
+			s.undo = s.undo[len(s.undo)-undoLimit:]
+		}
+		s.typedAt = time.Now()
+	}
+	s.redo = nil
+	s.current = next
+	s.typed = typed
+}
+
+// restore replaces the source with snap. The current file in show is selected if it exists in snap.
+func (s *UndoStore) restore(snap, show snapshot) {
+	s.current = snap
+	s.typed = ""
+	path, name := snap.currentPackage, snap.currentFile
+	if _, ok := snap.source[show.currentPackage][show.currentFile]; ok {
+		path, name = show.currentPackage, show.currentFile
+	}
+	s.app.Dispatch(&actions.RestoreSource{
+		Source:         copySource(snap.source),
+		CurrentPackage: path,
+		CurrentFile:    name,
+	})
+}
+
+func (s *UndoStore) snapshot() snapshot {
+	return snapshot{
+		source:         copySource(s.app.Source.Source()),
+		currentPackage: s.app.Editor.CurrentPackage(),
+		currentFile:    s.app.Editor.CurrentFile(),
+	}
+}
+
+func copySource(source map[string]map[string]string) map[string]map[string]string {
+	c := make(map[string]map[string]string, len(source))
+	for path, files := range source {
+		c[path] = make(map[string]string, len(files))
+		for name, contents := range files {
+			c[path][name] = contents
+		}
+	}
+	return c
+}
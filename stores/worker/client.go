@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"errors"
+
+	"github.com/dave/play/models"
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// ErrCancelled is returned by Compile when the compile is cancelled.
+var ErrCancelled = errors.New("compile cancelled")
+
+// Client sends compile requests to the worker. The worker compiles one request at a time, and the
+// responses are passed to the compile that sent the request.
+type Client struct {
+	worker *js.Object
+	sent   map[string]string // path -> hash of the archives the worker has been sent
+
+	// local is used instead of the worker when Web Workers aren't available
+	local *Compiler
+
+	last    int
+	pending map[int]*call // request ID -> compile that's waiting for the responses
+	cancel  chan struct{} // closed when the worker is terminated
+}
+
+// call is a compile that's waiting for the responses from the worker. done is closed when the
+// compile returns, so responses that are no longer wanted don't block.
+type call struct {
+	responses chan response
+	done      chan struct{}
+}
+
+func NewClient() *Client {
+	c := &Client{}
+	if script == "" || js.Global.Get("Worker") == js.Undefined {
+		c.local = NewCompiler()
+	}
+	return c
+}
+
+// Compile compiles req in the worker. The archives in cache that haven't been sent to the worker
+// are added to the request. progress is called with the path of each package as it's compiled.
+func (c *Client) Compile(req Request, cache map[string]Archive, progress func(path string)) ([]models.Dep, error) {
+	if c.local != nil {
+		for _, a := range cache {
+			req.Archives = append(req.Archives, a)
+		}
+		return c.local.Compile(req, progress)
+	}
+	if c.worker == nil {
+		c.start()
+	}
+	for path, a := range cache {
+		if c.sent[path] != a.Hash {
+			req.Archives = append(req.Archives, a)
+		}
+	}
+	c.last++
+	req.ID = c.last
+	b, err := encode(req)
+	if err != nil {
+		return nil, err
+	}
+	cl := &call{responses: make(chan response), done: make(chan struct{})}
+	c.pending[req.ID] = cl
+	defer func() {
+		delete(c.pending, req.ID)
+		close(cl.done)
+	}()
+	c.worker.Call("postMessage", b)
+	for _, a := range req.Archives {
+		c.sent[a.Path] = a.Hash
+	}
+	cancel := c.cancel
+	for {
+		select {
+		case r := <-cl.responses:
+			if !r.Done {
+				progress(r.Progress)
+				continue
+			}
+			if r.Err != nil {
+				return nil, r.Err.Error()
+			}
+			return r.Deps, nil
+		case <-cancel:
+			return nil, ErrCancelled
+		}
+	}
+}
+
+// Cancel stops the running compiles. The worker is terminated, so it's restarted (and sent all the
+// archives again) by the next compile.
+func (c *Client) Cancel() {
+	if c.worker == nil {
+		return
+	}
+	c.worker.Call("terminate")
+	c.worker = nil
+	close(c.cancel)
+}
+
+func (c *Client) start() {
+	c.worker = js.Global.Get("Worker").New(script)
+	c.sent = map[string]string{}
+	c.pending = map[int]*call{}
+	c.cancel = make(chan struct{})
+	c.worker.Set("onmessage", func(e *js.Object) {
+		var r response
+		if err := decode(e.Get("data"), &r); err != nil {
+			r = response{Done: true, Err: newFailure(err)}
+		}
+		c.deliver(r)
+	})
+	c.worker.Set("onerror", func(e *js.Object) {
+		c.deliver(response{Done: true, Err: &failure{Message: "compile worker: " + e.Get("message").String()}})
+	})
+}
+
+// deliver passes r to the compile that sent the request, or to all of the compiles if r has no ID.
+// This is called from the message handler, so mustn't block.
+func (c *Client) deliver(r response) {
+	for id, cl := range c.pending {
+		if r.ID != 0 && r.ID != id {
+			continue
+		}
+		cl := cl
+		go func() {
+			select {
+			case cl.responses <- r:
+			case <-cl.done:
+			}
+		}()
+	}
+}
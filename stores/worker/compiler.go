@@ -0,0 +1,183 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"go/types"
+	"sort"
+
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores/builderjs"
+	"github.com/gopherjs/gopherjs/compiler"
+)
+
+// Compiler compiles the source packages of the project. It runs in the worker, or on the page when
+// Web Workers aren't supported.
+type Compiler struct {
+	// archives (path -> archive) of the packages that aren't in the source
+	archives map[string]Archive
+
+	// compiled (path -> item) caches the most recent build of each source package, so unchanged
+	// packages aren't compiled again
+	compiled map[string]compiledItem
+}
+
+type compiledItem struct {
+	Key       string // see buildKey
+	Archive   *compiler.Archive
+	Js        []byte
+	SourceMap []byte
+}
+
+func NewCompiler() *Compiler {
+	return &Compiler{
+		archives: map[string]Archive{},
+		compiled: map[string]compiledItem{},
+	}
+}
+
+// Compile compiles the main package (or the tests) in the request and returns the JS of all the
+// dependencies in the order they should be loaded. progress is called before each source package
+// is compiled.
+func (c *Compiler) Compile(req Request, progress func(path string)) ([]models.Dep, error) {
+	for _, a := range req.Archives {
+		c.archives[a.Path] = a
+	}
+	path, source, test := req.Path, req.Source, ""
+	if req.Test != "" {
+		var err error
+		source, err = builderjs.TestSource(req.Test, req.Source, req.Tags)
+		if err != nil {
+			return nil, err
+		}
+		path, test = builderjs.TestMainPath, req.Test
+	}
+	var importsErr error
+	imports := func(p string) []string {
+		if req.Imports != nil && test == "" {
+			return req.Imports[p]
+		}
+		imps, err := builderjs.Imports(source[p], req.Tags, test != "" && (p == test || p == test+"_test"))
+		if err != nil && importsErr == nil {
+			importsErr = err
+		}
+		return imps
+	}
+	deps, err := c.compile(path, source, imports, req.Tags, req.Minify, test, progress)
+	if err != nil {
+		return nil, err
+	}
+	if importsErr != nil {
+		return nil, importsErr
+	}
+	return deps, nil
+}
+
+// compile compiles path and all its dependencies. Packages in source are compiled, others are
+// loaded from the archives. If test is not empty, the tests in that package are included.
+func (c *Compiler) compile(path string, source map[string]map[string]string, imports func(string) []string, tags []string, minify bool, test string, progress func(string)) ([]models.Dep, error) {
+	done := make(map[string]bool)
+	archives := map[string]*compiler.Archive{}
+	packages := map[string]*types.Package{}
+	jsdeps := []models.Dep{
+		// Always start with the prelude
+		{Path: "prelude", Js: c.archives["prelude"].Js},
+	}
+	var deps []*compiler.Archive
+	built := map[string]*compiler.Archive{}
+	var compile func(path string) error
+	compile = func(path string) error {
+		if done[path] {
+			return nil
+		}
+		if source[path] != nil {
+			imps := imports(path)
+			for _, imp := range imps {
+				if err := compile(imp); err != nil {
+					return err
+				}
+			}
+			isTest := test != "" && (path == test || path == test+"_test")
+			key := buildKey(path, source[path], imps, built, tags, minify, isTest)
+			item, ok := c.compiled[path]
+			if !ok || item.Key != key {
+				progress(path)
+				archive, err := builderjs.BuildPackage(
+					path,
+					source,
+					tags,
+					deps,
+					minify,
+					isTest,
+					archives,
+					packages,
+				)
+				if err != nil {
+					return err
+				}
+				js, sourceMap, err := builderjs.GetPackageCodeAndSourceMap(context.Background(), archive, false, true)
+				if err != nil {
+					return err
+				}
+				item = compiledItem{Key: key, Archive: archive, Js: js, SourceMap: sourceMap}
+				c.compiled[path] = item
+			}
+			jsdeps = append(jsdeps, models.Dep{Path: path, Js: item.Js, SourceMap: item.SourceMap})
+			deps = append(deps, item.Archive)
+			built[path] = item.Archive
+			done[path] = true
+			return nil
+		}
+		item, ok := c.archives[path]
+		if !ok {
+			return fmt.Errorf("%s not found", path)
+		}
+		for _, imp := range item.Archive.Imports {
+			if err := compile(imp); err != nil {
+				return err
+			}
+		}
+		jsdeps = append(jsdeps, models.Dep{Path: path, Js: item.Js})
+		deps = append(deps, item.Archive)
+		built[path] = item.Archive
+		done[path] = true
+		return nil
+	}
+	if err := compile("runtime"); err != nil {
+		return nil, err
+	}
+	if err := compile(path); err != nil {
+		return nil, err
+	}
+	return jsdeps, nil
+}
+
+// buildKey returns the key of a build of source package path. The key changes if the files, build
+// tags, minify flag or the export data of any direct import changes, so a package is compiled again
+// when it or one of its dependencies is changed.
+func buildKey(path string, files map[string]string, imports []string, built map[string]*compiler.Archive, tags []string, minify, test bool) string {
+	sha := sha1.New()
+	fmt.Fprintf(sha, "%s\x00%v\x00%v\x00", path, minify, test)
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	fmt.Fprintf(sha, "%q\x00", sorted)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(sha, "%s\x00%d\x00%s", name, len(files[name]), files[name])
+	}
+	imps := append([]string(nil), imports...)
+	sort.Strings(imps)
+	for _, imp := range imps {
+		fmt.Fprintf(sha, "%s\x00", imp)
+		if a := built[imp]; a != nil {
+			fmt.Fprintf(sha, "%d\x00", len(a.ExportData))
+			sha.Write(a.ExportData)
+		}
+	}
+	return fmt.Sprintf("%x", sha.Sum(nil))
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// script is the URL of this script, which is started again as the worker. It's empty if the
+// script was loaded in a way that doesn't set document.currentScript.
+var script string
+
+func init() {
+	if js.Global == nil || IsWorker() {
+		// not running in JS (e.g. tests), or already in the worker
+		return
+	}
+	if s := js.Global.Get("document").Get("currentScript"); s != nil && s != js.Undefined {
+		script = s.Get("src").String()
+	}
+}
+
+// IsWorker is true if the script is running in a Web Worker.
+func IsWorker() bool {
+	return js.Global.Get("document") == js.Undefined && js.Global.Get("importScripts") != js.Undefined
+}
+
+// Serve compiles the requests sent to the worker. It never returns.
+func Serve() {
+	c := NewCompiler()
+	requests := make(chan Request)
+	js.Global.Set("onmessage", func(e *js.Object) {
+		var req Request
+		if err := decode(e.Get("data"), &req); err != nil {
+			go post(response{Done: true, Err: newFailure(err)})
+			return
+		}
+		go func() { requests <- req }()
+	})
+	for req := range requests {
+		deps, err := c.Compile(req, func(path string) {
+			post(response{ID: req.ID, Progress: path})
+		})
+		post(response{ID: req.ID, Done: true, Deps: deps, Err: newFailure(err)})
+	}
+}
+
+func post(r response) {
+	b, err := encode(r)
+	if err != nil {
+		b, _ = encode(response{ID: r.ID, Done: true, Err: newFailure(err)})
+	}
+	js.Global.Call("postMessage", b)
+}
@@ -0,0 +1,140 @@
+// Package worker compiles the source packages of the project in a Web Worker, so the page stays
+// responsive during a compile. The page and the worker run the same script: main calls Serve when
+// the script is started in a worker.
+package worker
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"go/scanner"
+	"go/token"
+	"go/types"
+
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores/builderjs"
+	"github.com/gopherjs/gopherjs/compiler"
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Request is a compile request sent to the worker.
+type Request struct {
+	ID     int    // ID is sent back in the responses, so they reach the compile that sent the request
+	Path   string // Path is the main package
+	Test   string // If Test is not empty, the tests in this package are compiled and Path is ignored
+	Source map[string]map[string]string
+	Tags   []string
+	Minify bool
+
+	// Imports (package path -> imports) of the packages in Source. If nil, the imports are parsed
+	// from the source.
+	Imports map[string][]string
+
+	// Archives of the packages that aren't in the source. Only the archives that the worker hasn't
+	// been sent already are included.
+	Archives []Archive
+}
+
+// Archive is a compiled package downloaded from the compile server.
+type Archive struct {
+	Path    string
+	Hash    string
+	Archive *compiler.Archive // Archive is nil for the prelude
+	Js      []byte
+}
+
+// response is sent by the worker. Progress messages are sent before each source package is
+// compiled, followed by a final message with the result. ID is the ID of the request, or zero if the
+// failure isn't from a request (e.g. the request couldn't be decoded), in which case it's sent to
+// all of the compiles.
+type response struct {
+	ID       int
+	Progress string
+	Done     bool
+	Deps     []models.Dep
+	Err      *failure
+}
+
+// failure is an error that can be sent from the worker. Compile errors are sent with their
+// positions, so they can be shown as diagnostics.
+type failure struct {
+	Path    string // Path is set for a *builderjs.CompileError
+	Message string
+	Errors  []position
+}
+
+type position struct {
+	Filename     string
+	Line, Column int
+	Message      string
+}
+
+func newFailure(err error) *failure {
+	if err == nil {
+		return nil
+	}
+	ce, ok := err.(*builderjs.CompileError)
+	if !ok {
+		return &failure{Message: err.Error()}
+	}
+	f := &failure{Path: ce.Path, Message: ce.Error()}
+	for _, e := range ce.Errors {
+		f.Errors = append(f.Errors, positions(e)...)
+	}
+	return f
+}
+
+func positions(err error) []position {
+	switch err := err.(type) {
+	case scanner.ErrorList:
+		var p []position
+		for _, e := range err {
+			p = append(p, positions(e)...)
+		}
+		return p
+	case *scanner.Error:
+		return []position{{err.Pos.Filename, err.Pos.Line, err.Pos.Column, err.Msg}}
+	case scanner.Error:
+		return []position{{err.Pos.Filename, err.Pos.Line, err.Pos.Column, err.Msg}}
+	case types.Error:
+		pos := err.Fset.Position(err.Pos)
+		return []position{{pos.Filename, pos.Line, pos.Column, err.Msg}}
+	}
+	// errors without a position are parsed from the message by the diagnostics store
+	return []position{{Message: err.Error()}}
+}
+
+// Error converts the failure back to the error returned by the compiler.
+func (f *failure) Error() error {
+	if f.Path == "" {
+		return errors.New(f.Message)
+	}
+	ce := &builderjs.CompileError{Path: f.Path}
+	for _, p := range f.Errors {
+		if p.Filename == "" {
+			ce.Errors = append(ce.Errors, errors.New(p.Message))
+			continue
+		}
+		ce.Errors = append(ce.Errors, &scanner.Error{
+			Pos: token.Position{Filename: p.Filename, Line: p.Line, Column: p.Column},
+			Msg: p.Message,
+		})
+	}
+	return ce
+}
+
+func encode(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode decodes the data of a message event into v
+func decode(data *js.Object, v interface{}) error {
+	data = js.Global.Get("Uint8Array").New(data)
+	b := make([]byte, data.Length())
+	js.InternalObject(b).Get("$array").Call("set", data)
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
@@ -0,0 +1,562 @@
+package stores
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dave/flux"
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+)
+
+func NewWorkspaceStore(app *App) *WorkspaceStore {
+	s := &WorkspaceStore{
+		app: app,
+		tab: fmt.Sprintf("%x", time.Now().UnixNano()),
+	}
+	return s
+}
+
+// WorkspaceStore persists projects. Each workspace has its own source, build tags and editor state.
+// The list of workspaces is stored in local storage at "workspaces", the current workspace at
+// "workspace", and the state of each workspace at "workspace-<id>". The source is stored in
+// IndexedDB with a record per file, and only the files that have changed are written.
+type WorkspaceStore struct {
+	app *App
+
+	db         Database // nil if IndexedDB isn't available, in which case the source is in local storage
+	workspaces []models.Workspace
+
+	// current is the ID of the current workspace. This is empty when a project has been loaded from
+	// the page path, and the workspace is created (with the name in name) when it's first saved.
+	current string
+	name    string
+
+	// saved is the file records of the current workspace as last written to IndexedDB. revision is
+	// only incremented when the content (see workspaceContent) changes, and state is the state as
+	// last written, so saves that don't change anything aren't written.
+	saved    map[string]string
+	revision int
+	content  string
+	state    string
+
+	// tab identifies this tab. conflict is true when another tab has saved the current workspace,
+	// and saving is paused until the conflict is resolved.
+	tab      string
+	conflict bool
+}
+
+const filesStore = "files"
+
+func (s *WorkspaceStore) Workspaces() []models.Workspace {
+	return s.workspaces
+}
+
+// Current returns the ID of the current workspace, or an empty string if it hasn't been saved yet.
+func (s *WorkspaceStore) Current() string {
+	return s.current
+}
+
+// Name returns the name of the current workspace.
+func (s *WorkspaceStore) Name() string {
+	if w, ok := s.find(s.current); ok {
+		return w.Name
+	}
+	return s.name
+}
+
+// Conflict is true when another tab has changed the current workspace.
+func (s *WorkspaceStore) Conflict() bool {
+	return s.conflict
+}
+
+// Data returns the saved state of the current workspace.
+func (s *WorkspaceStore) Data() (data models.WorkspaceData, found bool, err error) {
+	if s.current == "" {
+		return models.WorkspaceData{}, false, nil
+	}
+	data, found, err = s.read(s.current)
+	if err != nil || !found {
+		return data, found, err
+	}
+	s.saved = records(s.current, data.Source)
+	s.revision = data.Revision
+	s.content = fingerprint(contentOf(data))
+	s.state = ""
+	return data, true, nil
+}
+
+func (s *WorkspaceStore) Handle(payload *flux.Payload) bool {
+	switch a := payload.Action.(type) {
+	case *actions.Load:
+		db, err := s.app.Storage.Database("play", 1, filesStore)
+		if err != nil {
+			s.app.Warn(models.StorageSource, fmt.Errorf("source will be saved in local storage: %v", err))
+		} else {
+			s.db = db
+		}
+		if err := s.load(); err != nil {
+			s.app.Fail(models.StorageSource, err)
+			return true
+		}
+		if location := strings.Trim(s.app.Location.URL().Path, "/"); location != "" {
+			// a project loaded from the page path gets a new workspace when it's edited
+			s.current = ""
+			s.name = location
+			if shaRegex.MatchString(location) {
+				s.name = "Shared " + location[:7]
+			}
+		}
+		s.listen()
+		payload.Notify()
+	case *actions.UserChangedText, *actions.FormatCode, *actions.AddPackage, *actions.RemovePackage,
+		*actions.DragDrop, *actions.RestoreSource:
+		payload.Wait(s.app.Editor)
+		s.save(true, payload)
+	case *actions.AddFile, *actions.DeleteFile:
+		payload.Wait(s.app.Source)
+		s.save(true, payload)
+	case *actions.BuildTags, *actions.RunConfigs:
+		payload.Wait(s.app.Compile)
+		s.save(true, payload)
+	case *actions.MinifyToggleClick, *actions.SelectRunConfig:
+		// the selected run configuration doesn't create a workspace for a project loaded from the page path
+		payload.Wait(s.app.Compile)
+		s.save(false, payload)
+	case *actions.UserChangedFile, *actions.UserChangedPackage:
+		// changing file doesn't create a workspace for a project loaded from the page path
+		payload.Wait(s.app.Editor)
+		s.save(false, payload)
+	case *actions.LoadSource:
+		if a.Save {
+			payload.Wait(s.app.Editor)
+			s.save(true, payload)
+		}
+	case *actions.CreateWorkspace:
+		s.save(false, payload)
+		w := s.add(a.Name)
+		data := models.WorkspaceData{
+			Source:         map[string]map[string]string{"main": {"main.go": defaultFile}},
+			CurrentPackage: "main",
+			CurrentFile:    "main.go",
+		}
+		if err := s.write(w.ID, data, nil); err != nil {
+			s.app.Fail(models.StorageSource, err)
+			return true
+		}
+		s.open(w.ID, data)
+		payload.Notify()
+	case *actions.SwitchWorkspace:
+		if a.ID == s.current {
+			return true
+		}
+		s.save(false, payload)
+		data, _, err := s.read(a.ID)
+		if err != nil {
+			s.app.Fail(models.StorageSource, err)
+			return true
+		}
+		s.open(a.ID, data)
+		payload.Notify()
+	case *actions.RenameWorkspace:
+		for i, w := range s.workspaces {
+			if w.ID == a.ID {
+				s.workspaces[i].Name = a.Name
+			}
+		}
+		if a.ID == s.current || (a.ID == "" && s.current == "") {
+			s.name = a.Name
+		}
+		if err := s.app.Storage.Save("workspaces", s.workspaces); err != nil {
+			s.app.Fail(models.StorageSource, err)
+			return true
+		}
+		payload.Notify()
+	case *actions.DuplicateWorkspace:
+		s.save(false, payload)
+		data, err := s.snapshot(a.ID)
+		if err != nil {
+			s.app.Fail(models.StorageSource, err)
+			return true
+		}
+		w := s.add(a.Name)
+		if err := s.write(w.ID, data, nil); err != nil {
+			s.app.Fail(models.StorageSource, err)
+			return true
+		}
+		s.open(w.ID, data)
+		payload.Notify()
+	case *actions.DeleteWorkspace:
+		var workspaces []models.Workspace
+		for _, w := range s.workspaces {
+			if w.ID != a.ID {
+				workspaces = append(workspaces, w)
+			}
+		}
+		s.workspaces = workspaces
+		s.app.Storage.Delete("workspace-" + a.ID)
+		if s.db != nil && a.ID != "" {
+			if err := s.db.DeletePrefix(filesStore, a.ID+"/"); err != nil {
+				s.app.Fail(models.StorageSource, err)
+				return true
+			}
+		}
+		if err := s.app.Storage.Save("workspaces", s.workspaces); err != nil {
+			s.app.Fail(models.StorageSource, err)
+			return true
+		}
+		if a.ID == s.current || a.ID == "" {
+			// the deleted workspace must not be saved when switching
+			s.current = ""
+			s.name = ""
+			if len(s.workspaces) > 0 {
+				s.app.Dispatch(&actions.SwitchWorkspace{ID: s.workspaces[0].ID})
+			} else {
+				s.app.Dispatch(&actions.CreateWorkspace{Name: "Untitled"})
+			}
+		}
+		payload.Notify()
+	case *actions.WorkspaceChanged:
+		if a.ID == "" {
+			if _, err := s.app.Storage.Find("workspaces", &s.workspaces); err != nil {
+				s.app.Fail(models.StorageSource, err)
+				return true
+			}
+			payload.Notify()
+			return true
+		}
+		if a.ID != s.current || s.conflict {
+			return true
+		}
+		if a.Revision != 0 && a.Revision <= s.revision {
+			// the other tab only changed its editor state (e.g. the current file)
+			return true
+		}
+		s.conflict = true
+		s.app.Dispatch(&actions.ModalOpen{Modal: models.WorkspaceConflictModal})
+		payload.Notify()
+	case *actions.ReloadWorkspace:
+		data, found, err := s.read(s.current)
+		if err != nil {
+			s.app.Fail(models.StorageSource, err)
+			return true
+		}
+		if !found {
+			s.app.Fail(models.StorageSource, fmt.Errorf("%s was deleted in another tab", s.Name()))
+			return true
+		}
+		s.open(s.current, data)
+		payload.Notify()
+	}
+	return true
+}
+
+// listen watches for other tabs saving workspaces. Every save writes the state of the workspace, so
+// other tabs are notified, and the revision shows if the content changed.
+func (s *WorkspaceStore) listen() {
+	s.app.Storage.Watch(func(key, value string, deleted bool) {
+		switch {
+		case key == "workspaces":
+			s.app.Dispatch(&actions.WorkspaceChanged{})
+		case strings.HasPrefix(key, "workspace-"):
+			var data models.WorkspaceData
+			if !deleted {
+				// a deleted workspace has no value
+				if err := json.Unmarshal([]byte(value), &data); err != nil {
+					return
+				}
+			}
+			if data.Tab == s.tab {
+				return
+			}
+			s.app.Dispatch(&actions.WorkspaceChanged{ID: strings.TrimPrefix(key, "workspace-"), Revision: data.Revision})
+		}
+	})
+}
+
+// save saves the state of the project to the current workspace. If create is true and the project
+// doesn't have a workspace yet, one is created.
+func (s *WorkspaceStore) save(create bool, payload *flux.Payload) {
+	if s.conflict {
+		// don't overwrite the changes from the other tab until the user has chosen to reload or fork
+		return
+	}
+	if s.current == "" {
+		if !create {
+			return
+		}
+		name := s.name
+		if name == "" {
+			name = "Untitled"
+		}
+		s.current = s.add(name).ID
+		s.saved = nil
+		s.state = ""
+		payload.Notify()
+	}
+	data := models.WorkspaceData{
+		Source:         s.app.Source.Source(),
+		Tags:           s.app.Compile.Tags(),
+		Run:            s.app.Compile.RunConfigs(),
+		RunConfig:      s.app.Compile.Selected(),
+		CurrentPackage: s.app.Editor.CurrentPackage(),
+		CurrentFile:    s.app.Editor.CurrentFile(),
+	}
+	state := fingerprint(data)
+	if state == s.state {
+		return
+	}
+	if err := s.write(s.current, data, s.saved); err != nil {
+		s.app.Fail(models.StorageSource, err)
+		return
+	}
+	s.state = state
+	if err := s.app.Storage.Save("workspace", s.current); err != nil {
+		s.app.Fail(models.StorageSource, err)
+		return
+	}
+}
+
+// write saves the state of workspace id. Only the files that differ from saved are written to
+// IndexedDB.
+func (s *WorkspaceStore) write(id string, data models.WorkspaceData, saved map[string]string) error {
+	if id == s.current {
+		if content := fingerprint(contentOf(data)); content != s.content {
+			s.revision++
+			s.content = content
+		}
+		data.Revision = s.revision
+	}
+	data.Tab = s.tab
+	if s.db != nil {
+		current := records(id, data.Source)
+		put := map[string]string{}
+		for key, contents := range current {
+			if previous, ok := saved[key]; !ok || previous != contents {
+				put[key] = contents
+			}
+		}
+		var del []string
+		for key := range saved {
+			if _, ok := current[key]; !ok {
+				del = append(del, key)
+			}
+		}
+		if err := s.db.Update(filesStore, put, del); err != nil {
+			return err
+		}
+		if id == s.current {
+			s.saved = current
+		}
+		data.Source = nil
+	}
+	// the state is written after the source, so other tabs are notified when the source is complete
+	return s.app.Storage.Save("workspace-"+id, data)
+}
+
+// read returns the saved state of workspace id. Source saved in local storage (before IndexedDB was
+// used, or in a browser without it) is moved to IndexedDB.
+func (s *WorkspaceStore) read(id string) (models.WorkspaceData, bool, error) {
+	var data models.WorkspaceData
+	found, err := s.app.Storage.Find("workspace-"+id, &data)
+	if err != nil || !found || s.db == nil {
+		return data, found, err
+	}
+	if data.Source != nil {
+		if err := s.db.Update(filesStore, records(id, data.Source), nil); err != nil {
+			return data, false, err
+		}
+		source := data.Source
+		data.Source = nil
+		if err := s.app.Storage.Save("workspace-"+id, data); err != nil {
+			return data, false, err
+		}
+		data.Source = source
+		return data, true, nil
+	}
+	recs, err := s.db.Prefix(filesStore, id+"/")
+	if err != nil {
+		return data, false, err
+	}
+	data.Source = map[string]map[string]string{}
+	for key, contents := range recs {
+		parts := strings.SplitN(strings.TrimPrefix(key, id+"/"), "\x00", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if data.Source[parts[0]] == nil {
+			data.Source[parts[0]] = map[string]string{}
+		}
+		data.Source[parts[0]][parts[1]] = contents
+	}
+	return data, true, nil
+}
+
+// records returns the IndexedDB records for the source of workspace id, keyed by
+// "<id>/<package path>\x00<filename>".
+func records(id string, source map[string]map[string]string) map[string]string {
+	recs := map[string]string{}
+	for path, files := range source {
+		for name, contents := range files {
+			recs[id+"/"+path+"\x00"+name] = contents
+		}
+	}
+	return recs
+}
+
+// workspaceContent is the part of the state of a workspace that's shared by the tabs. Changes to the
+// rest (e.g. the current file) don't conflict with other tabs.
+type workspaceContent struct {
+	Source map[string]map[string]string `json:"source,omitempty"`
+	Tags   []string                     `json:"tags,omitempty"`
+	Run    []models.RunConfig           `json:"run,omitempty"`
+}
+
+func contentOf(data models.WorkspaceData) workspaceContent {
+	return workspaceContent{Source: data.Source, Tags: data.Tags, Run: data.Run}
+}
+
+// fingerprint returns the JSON encoding of v, which is used to compare states
+func fingerprint(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// snapshot returns the state of workspace id. The unsaved project is used if id is empty.
+func (s *WorkspaceStore) snapshot(id string) (models.WorkspaceData, error) {
+	if id == "" || id == s.current {
+		// the copy is made from this tab, so any conflict with another tab is resolved
+		s.conflict = false
+		return models.WorkspaceData{
+			Source:         copySource(s.app.Source.Source()),
+			Tags:           s.app.Compile.Tags(),
+			Run:            s.app.Compile.RunConfigs(),
+			RunConfig:      s.app.Compile.Selected(),
+			CurrentPackage: s.app.Editor.CurrentPackage(),
+			CurrentFile:    s.app.Editor.CurrentFile(),
+		}, nil
+	}
+	data, found, err := s.read(id)
+	if err != nil {
+		return models.WorkspaceData{}, err
+	}
+	if !found {
+		return models.WorkspaceData{}, fmt.Errorf("workspace %s not found", id)
+	}
+	return data, nil
+}
+
+// add adds a workspace to the list
+func (s *WorkspaceStore) add(name string) models.Workspace {
+	w := models.Workspace{
+		ID:   fmt.Sprintf("%x", time.Now().UnixNano()),
+		Name: name,
+	}
+	s.workspaces = append(s.workspaces, w)
+	if err := s.app.Storage.Save("workspaces", s.workspaces); err != nil {
+		s.app.Fail(models.StorageSource, err)
+	}
+	return w
+}
+
+// open makes id the current workspace and loads its state
+func (s *WorkspaceStore) open(id string, data models.WorkspaceData) {
+	s.current = id
+	s.name = ""
+	s.conflict = false
+	s.saved = records(id, data.Source)
+	s.revision = data.Revision
+	s.content = fingerprint(contentOf(data))
+	s.state = ""
+	if err := s.app.Storage.Save("workspace", s.current); err != nil {
+		s.app.Fail(models.StorageSource, err)
+		return
+	}
+	s.app.Dispatch(&actions.RestoreSource{
+		Source:         data.Source,
+		CurrentPackage: data.CurrentPackage,
+		CurrentFile:    data.CurrentFile,
+		Reset:          true,
+	})
+	s.app.Dispatch(&actions.BuildTags{Tags: data.Tags})
+	s.app.Dispatch(&actions.RunConfigs{Configs: data.Run, Selected: data.RunConfig})
+}
+
+func (s *WorkspaceStore) find(id string) (models.Workspace, bool) {
+	for _, w := range s.workspaces {
+		if w.ID == id && id != "" {
+			return w, true
+		}
+	}
+	return models.Workspace{}, false
+}
+
+// load reads the list of workspaces and the current workspace. Projects saved before workspaces
+// were added are moved to a new workspace.
+func (s *WorkspaceStore) load() error {
+	found, err := s.app.Storage.Find("workspaces", &s.workspaces)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return s.migrate()
+	}
+	if _, err := s.app.Storage.Find("workspace", &s.current); err != nil {
+		return err
+	}
+	if _, ok := s.find(s.current); !ok {
+		s.current = ""
+		if len(s.workspaces) > 0 {
+			s.current = s.workspaces[0].ID
+		}
+	}
+	return nil
+}
+
+func (s *WorkspaceStore) migrate() error {
+	var data models.WorkspaceData
+	found, err := s.app.Storage.Find("source", &data.Source)
+	if err != nil {
+		return err
+	}
+	if !found {
+		// old format for storing files
+		var files map[string]string
+		found, err = s.app.Storage.Find("files", &files)
+		if err != nil {
+			return err
+		}
+		if found {
+			data.Source = map[string]map[string]string{"main": files}
+		}
+	}
+	if !found {
+		return nil
+	}
+	for key, value := range map[string]interface{}{
+		"current-file":    &data.CurrentFile,
+		"current-package": &data.CurrentPackage,
+		"build-tags":      &data.Tags,
+	} {
+		if _, err := s.app.Storage.Find(key, value); err != nil {
+			return err
+		}
+	}
+	w := s.add("Default")
+	if err := s.write(w.ID, data, nil); err != nil {
+		return err
+	}
+	s.current = w.ID
+	if err := s.app.Storage.Save("workspace", s.current); err != nil {
+		return err
+	}
+	for _, key := range []string{"source", "files", "current-file", "current-package", "build-tags"} {
+		s.app.Storage.Delete(key)
+	}
+	return nil
+}
@@ -69,14 +69,18 @@ func (v *AddFileModal) Render() vecty.ComponentOrHTML {
 func (v *AddFileModal) save(*vecty.Event) {
 	value := v.input.Node().Get("value").String()
 	if strings.Contains(value, "/") {
-		v.app.Fail(fmt.Errorf("filename %s must not contain a slash", value))
+		v.app.Fail(models.ProjectSource, fmt.Errorf("filename %s must not contain a slash", value))
 		return
 	}
 	if !strings.HasSuffix(value, ".go") && !strings.Contains(value, ".") {
 		value = value + ".go"
 	}
+	if value == models.RunConfigFile {
+		v.app.Fail(models.ProjectSource, fmt.Errorf("%s is reserved for run configurations", value))
+		return
+	}
 	if v.app.Source.HasFile(v.app.Editor.CurrentPackage(), value) {
-		v.app.Fail(fmt.Errorf("%s already exists", value))
+		v.app.Fail(models.ProjectSource, fmt.Errorf("%s already exists", value))
 		return
 	}
 	v.app.Dispatch(&actions.ModalClose{Modal: models.AddFileModal})
@@ -67,7 +67,7 @@ func (v *AddPackageModal) Render() vecty.ComponentOrHTML {
 func (v *AddPackageModal) save(*vecty.Event) {
 	value := v.input.Node().Get("value").String()
 	if v.app.Source.HasPackage(value) {
-		v.app.Fail(fmt.Errorf("%s already exists", value))
+		v.app.Fail(models.ProjectSource, fmt.Errorf("%s already exists", value))
 		return
 	}
 	v.app.Dispatch(&actions.ModalClose{Modal: models.AddPackageModal})
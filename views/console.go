@@ -0,0 +1,116 @@
+package views
+
+import (
+	"github.com/dave/play/actions"
+	"github.com/dave/play/stores"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/gopherjs/vecty/prop"
+)
+
+// Console is the output of the running program. The output is written to the #console element by
+// the console store.
+type Console struct {
+	vecty.Core
+	app *stores.App
+}
+
+func NewConsole(app *stores.App) *Console {
+	v := &Console{
+		app: app,
+	}
+	return v
+}
+
+func (v *Console) Render() vecty.ComponentOrHTML {
+	display := ""
+	if !v.app.Page.Console() {
+		display = "none"
+	}
+	return elem.Div(
+		vecty.Markup(
+			prop.ID("console-holder"),
+			vecty.Style("display", display),
+		),
+		elem.Div(
+			vecty.Markup(
+				vecty.Class("console-toolbar", "form-inline"),
+			),
+			elem.Input(
+				vecty.Markup(
+					prop.Type(prop.TypeSearch),
+					vecty.Class("form-control", "form-control-sm", "mr-2"),
+					prop.Placeholder("Search"),
+					prop.Value(v.app.Console.Query()),
+					event.Input(func(e *vecty.Event) {
+						v.app.Dispatch(&actions.ConsoleSearch{Query: e.Target.Get("value").String()})
+					}),
+				),
+			),
+			elem.Button(
+				vecty.Markup(
+					prop.Type(prop.TypeButton),
+					vecty.Class("btn", "btn-sm", "btn-light", "mr-2"),
+					event.Click(func(e *vecty.Event) {
+						v.app.Dispatch(&actions.ConsoleCopy{})
+					}).PreventDefault(),
+				),
+				vecty.Text("Copy"),
+			),
+			elem.Button(
+				vecty.Markup(
+					prop.Type(prop.TypeButton),
+					vecty.Class("btn", "btn-sm", "btn-light"),
+					event.Click(func(e *vecty.Event) {
+						v.app.Dispatch(&actions.ConsoleClear{})
+					}).PreventDefault(),
+				),
+				vecty.Text("Clear"),
+			),
+		),
+		elem.Preformatted(
+			vecty.Markup(
+				prop.ID("console"),
+			),
+		),
+		elem.Div(
+			vecty.Markup(
+				vecty.Class("console-stdin", "input-group", "input-group-sm"),
+			),
+			elem.Input(
+				vecty.Markup(
+					prop.Type(prop.TypeText),
+					vecty.Class("form-control"),
+					prop.Placeholder("Stdin: enter sends a line, ctrl+d sends EOF"),
+					event.KeyDown(func(e *vecty.Event) {
+						switch {
+						case e.Get("key").String() == "Enter":
+							v.app.Dispatch(&actions.StdinSend{Text: e.Target.Get("value").String()})
+							e.Target.Set("value", "")
+							e.Call("preventDefault")
+						case e.Get("key").String() == "d" && e.Get("ctrlKey").Bool():
+							v.app.Dispatch(&actions.StdinClose{})
+							e.Call("preventDefault")
+						}
+					}),
+				),
+			),
+			elem.Div(
+				vecty.Markup(
+					vecty.Class("input-group-append"),
+				),
+				elem.Button(
+					vecty.Markup(
+						prop.Type(prop.TypeButton),
+						vecty.Class("btn", "btn-light"),
+						event.Click(func(e *vecty.Event) {
+							v.app.Dispatch(&actions.StdinClose{})
+						}).PreventDefault(),
+					),
+					vecty.Text("EOF"),
+				),
+			),
+		),
+	)
+}
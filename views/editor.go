@@ -1,11 +1,13 @@
 package views
 
 import (
+	"reflect"
 	"time"
 
 	"strings"
 
 	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
 	"github.com/dave/play/stores"
 	"github.com/gopherjs/gopherjs/js"
 	"github.com/gopherjs/vecty"
@@ -19,7 +21,10 @@ type Editor struct {
 	vecty.Core
 	app *stores.App
 
-	editor ace.Editor
+	editor      ace.Editor
+	cursor      *models.Position
+	diagnostics []models.Diagnostic // diagnostics currently displayed
+	markers     []int               // ids of the error underline markers
 }
 
 func NewEditor(app *stores.App) *Editor {
@@ -40,6 +45,8 @@ func getEditorMode(filename string) string {
 		return "ace/mode/javascript"
 	case strings.HasSuffix(filename, ".md"):
 		return "ace/mode/markdown"
+	case strings.HasSuffix(filename, ".json"):
+		return "ace/mode/json"
 	default:
 		return "ace/mode/plain_text"
 	}
@@ -48,11 +55,28 @@ func getEditorMode(filename string) string {
 func (v *Editor) Mount() {
 	v.app.Watch(v, func(done chan struct{}) {
 		defer close(done)
+		var reset bool
 		if v.app.Source.Current() != v.editor.GetValue() {
 			// only update the editor if the text is changed
 			v.editor.SetValue(v.app.Source.Current())
 			v.editor.ClearSelection()
 			v.editor.MoveCursorTo(0, 0)
+			reset = true
+		}
+		diagnostics := v.app.Diagnostic.File(v.app.Editor.CurrentPackage(), v.app.Editor.CurrentFile())
+		if reset || !reflect.DeepEqual(diagnostics, v.diagnostics) {
+			v.showDiagnostics(diagnostics)
+		}
+		if cursor := v.app.Editor.Cursor(); cursor != v.cursor {
+			v.cursor = cursor
+			if cursor != nil {
+				column := cursor.Column - 1
+				if column < 0 {
+					column = 0
+				}
+				v.editor.Call("gotoLine", cursor.Line, column, false)
+				v.editor.Call("focus")
+			}
 		}
 		correctMode := getEditorMode(v.app.Editor.CurrentFile())
 		currentMode := v.editor.GetOption("mode").String()
@@ -124,6 +148,62 @@ func (v *Editor) Mount() {
 	})
 }
 
+// showDiagnostics displays the diagnostics as annotations in the gutter, and underlines the code at
+// each position.
+func (v *Editor) showDiagnostics(diagnostics []models.Diagnostic) {
+	v.diagnostics = diagnostics
+	session := v.editor.Call("getSession")
+	for _, id := range v.markers {
+		session.Call("removeMarker", id)
+	}
+	v.markers = nil
+	annotations := []js.M{}
+	aceRange := js.Global.Get("ace").Call("require", "ace/range").Get("Range")
+	for _, d := range diagnostics {
+		if d.Line == 0 {
+			continue
+		}
+		row := d.Line - 1
+		column := d.Column - 1
+		if column < 0 {
+			column = 0
+		}
+		annotations = append(annotations, js.M{
+			"row":    row,
+			"column": column,
+			"text":   d.Message,
+			"type":   "error",
+		})
+		start, end := underline(session.Call("getLine", row).String(), column)
+		id := session.Call("addMarker", aceRange.New(row, start, row, end), "ace-error-marker", "text", false).Int()
+		v.markers = append(v.markers, id)
+	}
+	session.Call("setAnnotations", annotations)
+}
+
+// underline returns the columns to underline for an error at column in line: the identifier at the
+// column, or a single character.
+func underline(line string, column int) (start, end int) {
+	if column >= len(line) {
+		if len(line) == 0 {
+			return 0, 1
+		}
+		return len(line) - 1, len(line)
+	}
+	end = column
+	for end < len(line) && isIdentChar(line[end]) {
+		end++
+	}
+	if end == column {
+		end = column + 1
+	}
+	return column, end
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
 func (v *Editor) Resize() {
 	if v.editor.Object != nil {
 		v.editor.Call("resize")
@@ -0,0 +1,197 @@
+package views
+
+import (
+	"fmt"
+
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/gopherjs/vecty/prop"
+)
+
+// maxNotifications is the number of notifications shown. The rest are in the errors modal.
+const maxNotifications = 3
+
+// Notifications shows the errors that haven't been dismissed
+type Notifications struct {
+	vecty.Core
+	app *stores.App
+}
+
+func NewNotifications(app *stores.App) *Notifications {
+	v := &Notifications{
+		app: app,
+	}
+	return v
+}
+
+func (v *Notifications) Render() vecty.ComponentOrHTML {
+	notifications := v.app.Error.Notifications()
+
+	items := []vecty.MarkupOrChild{
+		vecty.Markup(
+			vecty.Class("notifications"),
+		),
+	}
+	start := 0
+	if len(notifications) > maxNotifications {
+		start = len(notifications) - maxNotifications
+	}
+	for _, e := range notifications[start:] {
+		items = append(items, v.renderNotification(e))
+	}
+	if len(notifications) > 1 {
+		more := "Dismiss all"
+		if start > 0 {
+			more = fmt.Sprintf("Dismiss all (%d more)", start)
+		}
+		items = append(items, elem.Div(
+			vecty.Markup(
+				vecty.Class("text-right"),
+			),
+			elem.Anchor(
+				vecty.Markup(
+					prop.Href(""),
+					vecty.Class("badge", "badge-light"),
+					event.Click(func(e *vecty.Event) {
+						v.app.Dispatch(&actions.DismissError{})
+					}).PreventDefault(),
+				),
+				vecty.Text(more),
+			),
+		))
+	}
+
+	return elem.Div(items...)
+}
+
+func (v *Notifications) renderNotification(e models.Error) *vecty.HTML {
+	id := e.ID
+	return elem.Div(
+		vecty.Markup(
+			vecty.Class("alert", "alert-dismissible", severityClass(e.Severity)),
+			vecty.Property("role", "alert"),
+		),
+		elem.Strong(vecty.Text(string(e.Source)+" "+string(e.Severity))),
+		vecty.If(e.Count > 1, elem.Span(
+			vecty.Markup(vecty.Class("badge", "badge-pill", "badge-light", "ml-1")),
+			vecty.Text(fmt.Sprintf("×%d", e.Count)),
+		)),
+		elem.Div(vecty.Text(e.Message)),
+		elem.Anchor(
+			vecty.Markup(
+				prop.Href(""),
+				vecty.Class("alert-link", "small"),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.ModalOpen{Modal: models.ErrorsModal})
+				}).PreventDefault(),
+			),
+			vecty.Text("Error log"),
+		),
+		elem.Button(
+			vecty.Markup(
+				prop.Type(prop.TypeButton),
+				vecty.Class("close"),
+				vecty.Property("aria-label", "Close"),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.DismissError{ID: id})
+				}).PreventDefault(),
+			),
+			elem.Span(
+				vecty.Markup(
+					vecty.Property("aria-hidden", "true"),
+				),
+				vecty.Text("×"),
+			),
+		),
+	)
+}
+
+func severityClass(severity models.Severity) string {
+	if severity == models.WarningSeverity {
+		return "alert-warning"
+	}
+	return "alert-danger"
+}
+
+type ErrorsModal struct {
+	*Modal
+}
+
+func NewErrorsModal(app *stores.App) *ErrorsModal {
+	v := &ErrorsModal{
+		&Modal{
+			app:   app,
+			id:    models.ErrorsModal,
+			title: "Errors",
+			large: true,
+		},
+	}
+	return v
+}
+
+func (v *ErrorsModal) Render() vecty.ComponentOrHTML {
+	log := v.app.Error.Log()
+	if len(log) == 0 {
+		return v.Body(
+			elem.Paragraph(
+				vecty.Text("No errors have been reported."),
+			),
+		).Build()
+	}
+
+	rows := []vecty.MarkupOrChild{}
+	// newest first
+	for i := len(log) - 1; i >= 0; i-- {
+		e := log[i]
+		count := ""
+		if e.Count > 1 {
+			count = fmt.Sprintf("×%d", e.Count)
+		}
+		rows = append(rows, elem.TableRow(
+			vecty.Markup(
+				vecty.ClassMap{"table-warning": e.Severity == models.WarningSeverity, "table-danger": e.Severity != models.WarningSeverity && !e.Dismissed},
+			),
+			elem.TableData(vecty.Text(e.Time.Format("Jan 2 15:04:05"))),
+			elem.TableData(vecty.Text(string(e.Source))),
+			elem.TableData(vecty.Text(string(e.Severity))),
+			elem.TableData(vecty.Text(e.Message)),
+			elem.TableData(vecty.Text(count)),
+		))
+	}
+
+	return v.Body(
+		elem.Div(
+			vecty.Markup(
+				vecty.Style("max-height", "400px"),
+				vecty.Style("overflow", "auto"),
+			),
+			elem.Table(
+				vecty.Markup(vecty.Class("table", "table-sm", "small")),
+				elem.TableHead(
+					elem.TableRow(
+						elem.TableHeader(vecty.Text("Time")),
+						elem.TableHeader(vecty.Text("Source")),
+						elem.TableHeader(vecty.Text("Severity")),
+						elem.TableHeader(vecty.Text("Message")),
+						elem.TableHeader(),
+					),
+				),
+				elem.TableBody(rows...),
+			),
+		),
+		elem.Button(
+			vecty.Markup(
+				prop.Type(prop.TypeButton),
+				vecty.Class("btn", "btn-outline-secondary", "btn-sm"),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.ClearErrors{})
+				}).PreventDefault(),
+			),
+			vecty.Text("Clear log"),
+		),
+	).Build()
+}
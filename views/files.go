@@ -0,0 +1,100 @@
+package views
+
+import (
+	"fmt"
+
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/gopherjs/vecty/prop"
+)
+
+type FilesModal struct {
+	*Modal
+}
+
+func NewFilesModal(app *stores.App) *FilesModal {
+	v := &FilesModal{
+		&Modal{
+			app:   app,
+			id:    models.FilesModal,
+			title: "Files",
+			large: true,
+		},
+	}
+	return v
+}
+
+func (v *FilesModal) Render() vecty.ComponentOrHTML {
+	written := v.app.Filesystem.Written()
+	if len(written) == 0 {
+		return v.Body(
+			elem.Paragraph(
+				vecty.Text("The program hasn't written any files. Files that the program writes are shown here after it's run, so they can be viewed and downloaded."),
+			),
+		).Build()
+	}
+
+	rows := []vecty.MarkupOrChild{}
+	for _, name := range written {
+		name := name
+		rows = append(rows, elem.TableRow(
+			vecty.Markup(
+				vecty.ClassMap{"table-active": name == v.app.Filesystem.Viewing()},
+			),
+			elem.TableData(
+				elem.Anchor(
+					vecty.Markup(
+						prop.Href(""),
+						event.Click(func(e *vecty.Event) {
+							v.app.Dispatch(&actions.ViewFile{Name: name})
+						}).PreventDefault(),
+					),
+					vecty.Text(name),
+				),
+			),
+			elem.TableData(vecty.Text(fmt.Sprintf("%d bytes", len(v.app.Filesystem.Contents(name))))),
+			elem.TableData(
+				elem.Anchor(
+					vecty.Markup(
+						prop.Href(""),
+						event.Click(func(e *vecty.Event) {
+							v.app.Dispatch(&actions.DownloadFile{Name: name})
+						}).PreventDefault(),
+					),
+					vecty.Text("Download"),
+				),
+			),
+		))
+	}
+
+	var contents *vecty.HTML
+	if viewing := v.app.Filesystem.Viewing(); viewing != "" {
+		contents = elem.Preformatted(
+			vecty.Markup(
+				vecty.Class("border", "p-2"),
+				vecty.Style("max-height", "300px"),
+				vecty.Style("overflow", "auto"),
+			),
+			vecty.Text(string(v.app.Filesystem.Contents(viewing))),
+		)
+	}
+
+	return v.Body(
+		elem.Table(
+			vecty.Markup(vecty.Class("table", "table-sm")),
+			elem.TableHead(
+				elem.TableRow(
+					elem.TableHeader(vecty.Text("File")),
+					elem.TableHeader(vecty.Text("Size")),
+					elem.TableHeader(),
+				),
+			),
+			elem.TableBody(rows...),
+		),
+		vecty.If(contents != nil, contents),
+	).Build()
+}
@@ -105,7 +105,17 @@ The URL can be used to initialise with code in several ways:
 
 #### Run
 Click the ` + "`" + `Run` + "`" + ` button to run your code in the right-hand panel. If the imports have been changed recently,
-the dependencies will be refreshed before running.
+the dependencies will be refreshed before running. Compiled dependencies are cached in the browser, so 
+they are only downloaded again when they change. Packages in your project are only compiled again when 
+they, or one of their dependencies, have changed. Compiling runs in the background so you can keep 
+editing. Use the ` + "`" + `Stop` + "`" + ` option to stop a running program. While compiling or talking to the server, the 
+` + "`" + `Run` + "`" + ` button changes to ` + "`" + `Stop` + "`" + `, which cancels the compile or abandons the download, share or deploy.
+
+<table></table>
+
+#### Test
+Use the ` + "`" + `Test` + "`" + ` option to run the tests in the current package. A main package that runs the tests is 
+generated (as ` + "`" + `go test` + "`" + ` would), and the results are written to the console. ` + "`" + `TestMain` + "`" + ` is not supported.
 
 <table></table>
 
@@ -117,6 +127,13 @@ Use the ` + "`" + `Format code` + "`" + ` option to run ` + "`" + `gofmt` + "`"
 
 <table></table>
 
+#### Undo
+Use the ` + "`" + `Undo` + "`" + ` and ` + "`" + `Redo` + "`" + ` options (or ` + "`" + `Ctrl+Z` + "`" + ` and ` + "`" + `Ctrl+Shift+Z` + "`" + ` / ` + "`" + `Ctrl+Y` + "`" + `, ` + "`" + `Cmd` + "`" + ` on a Mac) to undo and redo 
+changes across the whole project. As well as editing, adding or deleting files and packages, uploading 
+files and formatting code can be undone. Typing in a file is undone in steps of a second.
+
+<table></table>
+
 <img align="right" width="150" alt="update" src="https://user-images.githubusercontent.com/925351/39422115-557afea2-4c6c-11e8-9af5-fb98f582ae6d.png">
 
 #### Update
@@ -150,8 +167,37 @@ own website. See [github.com/dave/jsgo](https://github.com/dave/jsgo) for more i
 <img align="right" width="150" alt="console" src="https://user-images.githubusercontent.com/925351/39422096-53904c3c-4c6c-11e8-94f6-2c8f62c1f9a3.png">
 
 #### Console
-Writes to ` + "`" + `os.Stdout` + "`" + ` are redirected to a playground console, which can be toggled using the ` + "`" + `Show console` + "`" + `
-option. The console will automatically appear the first time it's written to.
+Writes to ` + "`" + `os.Stdout` + "`" + ` and ` + "`" + `os.Stderr` + "`" + ` are redirected to a playground console, which can be toggled using 
+the ` + "`" + `Show console` + "`" + ` option. The console will automatically appear the first time it's written to. Stderr is 
+shown in red, and ANSI colour and style escape codes are rendered. Output is escaped, so HTML is shown as text. 
+The toolbar has a search box which filters the lines, and buttons to copy the output to the clipboard and clear 
+the console. The last 5000 lines are kept. Uncaught panics are printed with their stack trace, and positions in 
+your code are shown as links to the Go source. Source maps are also added, so the browser's developer tools 
+show the Go source.
+
+The input line under the console sends input to ` + "`" + `os.Stdin` + "`" + `: press enter to send a line, and ` + "`" + `ctrl+d` + "`" + ` 
+or the ` + "`" + `EOF` + "`" + ` button to end the input. A program that reads when there's no input waits for a line. The input 
+is discarded when a program starts or is stopped. GopherJS programs only wait if the standard library was 
+compiled by ` + "`" + `playserver` + "`" + ` (their system calls are synchronous, so it replaces ` + "`" + `syscall.Read` + "`" + `), and get EOF 
+from other servers.
+
+<table></table>
+
+#### Files
+The program runs with a virtual file system, so ` + "`" + `os.Open` + "`" + `, ` + "`" + `ioutil.ReadFile` + "`" + `, ` + "`" + `ioutil.WriteFile` + "`" + ` etc. 
+work. It contains the files in the project that aren't Go source (e.g. ` + "`" + `.json` + "`" + `, ` + "`" + `.txt` + "`" + ` or ` + "`" + `.csv` + "`" + ` 
+files), in a directory for each package (` + "`" + `/main/data.json` + "`" + `), and the working directory is the directory of 
+the package that is run, so ` + "`" + `os.Open("data.json")` + "`" + ` opens a file in the same package. Files in a 
+` + "`" + `testdata` + "`" + ` directory are in a package ending ` + "`" + `/testdata` + "`" + `. The file system is reset each time the 
+program is run. Files written by the program are listed by the ` + "`" + `Files` + "`" + ` option 
+after the run, where they can be viewed and downloaded.
+
+<table></table>
+
+#### Problems
+Compile errors are listed in the problems panel below the editor, and marked in the editor gutter. Click an 
+error to jump to the file and line. The package being edited is also type-checked in the background as you 
+type, so most errors are shown without needing to run.
 
 <table></table>
 
@@ -170,6 +216,59 @@ tags are persisted when using the ` + "`" + `Share` + "`" + ` feature.
 
 <table></table>
 
+#### Run configurations
+Run configurations are selected with the menu next to the ` + "`" + `Run` + "`" + ` button. ` + "`" + `Current package` + "`" + ` runs the 
+package being edited (or the only main package) as before. A named run configuration has a main package, build 
+tags, command line arguments (` + "`" + `os.Args` + "`" + `), environment variables (` + "`" + `os.Getenv` + "`" + `) and the ` + "`" + `Minify JS` + "`" + ` 
+setting, so projects with several main packages can keep e.g. "server demo" and "client demo" side by side. 
+Selecting a configuration changes the build tags and minify setting to its own, and changes to them are saved in 
+the selected configuration. Run configurations are saved with the workspace and persisted when using the 
+` + "`" + `Share` + "`" + ` feature. ` + "`" + `os.Args[0]` + "`" + ` is the package path, and ` + "`" + `PWD` + "`" + ` is set to the working directory (see 
+Files).
+
+A run configuration can use the WebAssembly target instead of GopherJS. The main package is compiled by the go 
+command (` + "`" + `GOOS=js GOARCH=wasm` + "`" + `) on the server and run with ` + "`" + `wasm_exec.js` + "`" + `, so the behaviour of the two 
+targets can be compared. Only self-hosted servers (` + "`" + `playserver` + "`" + `) support this. Output and input use the 
+same console, but the virtual file system isn't available.
+
+<table></table>
+
+#### Server
+The compile server can be changed with the ` + "`" + `Server...` + "`" + ` option, which is persisted in local storage. Enter the 
+base URL of a self-hosted server (e.g. ` + "`" + `http://localhost:8080` + "`" + `), or leave it empty to use the server the editor is served 
+from (e.g. ` + "`" + `playserver -static` + "`" + `, see below). The jsgo.io compile server has been shut down. The server can 
+also be set for one page load with the ` + "`" + `server` + "`" + ` query parameter (e.g. ` + "`" + `/?server=http://localhost:8080` + "`" + `), 
+which isn't saved, or with a ` + "`" + `<meta name="play-server" content="...">` + "`" + ` tag in the page.
+
+If the connection to the server is lost, it's opened again after a short delay (doubling each time, up to 
+4 retries) and the request is sent again. Archives that were already downloaded aren't requested again. 
+Downloading an archive is also retried if the package host fails. The state of the connection is shown 
+next to the status message.
+
+Operations (update, share, deploy etc.) share one connection to the server, so the project can be shared 
+or deployed while the archives are updating. Each message on the websocket is wrapped with the ID of its 
+operation (` + "`" + `{"id": 1, "message": ...}` + "`" + `), and the server ends an operation with ` + "`" + `{"id": 1, "done": true}` + "`" + `. 
+` + "`" + `playserver` + "`" + ` supports this. For servers that don't (e.g. jsgo.io), each operation uses its own 
+connection. ` + "`" + `Stop` + "`" + ` cancels the updates but not shares or deploys.
+
+<table></table>
+
+#### Modules
+Add a ` + "`" + `go.mod` + "`" + ` file to a package to pin the versions of dependencies. The required versions are used when 
+archives are compiled and when packages are loaded with the ` + "`" + `Load package` + "`" + ` option. The module path and 
+requirements are shown with the ` + "`" + `Module...` + "`" + ` option. If the requirements change, the archives are updated 
+before the next run.
+
+<table></table>
+
+#### Errors
+Errors are shown as notifications in the bottom left corner until they are closed. Each is labelled with 
+where it came from (network, compile, storage, runtime or project), and repeated errors are combined with 
+a count. Warnings (e.g. when the browser doesn't allow the archive cache) are shown in yellow. The 
+` + "`" + `Errors...` + "`" + ` option shows the log of recent errors, which is kept between page loads.
+
+<table></table>
+
 <img align="right" width="150" alt="download" src="https://user-images.githubusercontent.com/925351/39422103-54358530-4c6c-11e8-8dbb-23b109bab9f8.png">
 
 #### Download
@@ -186,6 +285,15 @@ can be uploaded to restore a multi-file project.
 
 <table></table>
 
+#### Workspaces
+Projects are saved in the browser (in IndexedDB) as workspaces. Use the workspace menu to switch between workspaces, 
+and the ` + "`" + `New workspace` + "`" + `, ` + "`" + `Rename workspace` + "`" + `, ` + "`" + `Duplicate workspace` + "`" + ` and ` + "`" + `Delete workspace` + "`" + ` options to 
+manage them. Each workspace keeps its own files, build tags and selected file. A project loaded from the 
+URL is saved to a new workspace when it's edited. If the current workspace is changed in another tab, 
+changes stop being saved until you choose to reload the workspace or fork it to a new one.
+
+<table></table>
+
 <img align="right" width="150" alt="files" src="https://user-images.githubusercontent.com/925351/39422104-544e3f8a-4c6c-11e8-9953-002ae51db341.png">
 
 #### File menu
@@ -197,7 +305,7 @@ Change the selected file with the file menu.
 
 #### Add file
 Add a file to the current package with the ` + "`" + `Add file` + "`" + ` option. Only ` + "`" + `.go` + "`" + `, ` + "`" + `.md` + "`" + ` and ` + "`" + `.inc.js` + "`" + ` files are
-supported. If no extension is supplied, ` + "`" + `.go` + "`" + ` is added.
+supported, along with ` + "`" + `go.mod` + "`" + ` and ` + "`" + `go.sum` + "`" + `. If no extension is supplied, ` + "`" + `.go` + "`" + ` is added.
 
 <table></table>
 
@@ -228,6 +336,7 @@ Add an empty package with the ` + "`" + `Add package` + "`" + ` option.
 The source for an import or dependency can be loaded with the ` + "`" + `Load package` + "`" + ` option. By default, only 
 the direct imports of your project are listed. Use the ` + "`" + `Show all dependencies` + "`" + ` option to show the entire
 dependency tree.
+Enter a path to load any package, optionally at a specific version (e.g. ` + "`" + `github.com/foo/bar@v1.2.3` + "`" + `).
 
 <table></table>
 
@@ -2,6 +2,7 @@ package views
 
 import (
 	"sort"
+	"strings"
 
 	"github.com/dave/play/actions"
 	"github.com/dave/play/models"
@@ -15,6 +16,7 @@ import (
 type LoadPackageModal struct {
 	*Modal
 	imps *vecty.HTML
+	path *vecty.HTML
 }
 
 func NewLoadPackageModal(app *stores.App) *LoadPackageModal {
@@ -65,6 +67,13 @@ func (v *LoadPackageModal) Render() vecty.ComponentOrHTML {
 	}
 	v.imps = elem.Select(items...)
 
+	v.path = elem.Input(vecty.Markup(
+		vecty.Class("form-control"),
+		prop.Type(prop.TypeText),
+		prop.ID("load-package-path-input"),
+		prop.Placeholder("e.g. github.com/foo/bar@v1.2.3"),
+	))
+
 	infoDisplay := "none"
 	if v.app.Page.ShowAllDeps() && !v.app.Archive.AllFresh() {
 		infoDisplay = ""
@@ -85,6 +94,25 @@ func (v *LoadPackageModal) Render() vecty.ComponentOrHTML {
 				),
 				v.imps,
 			),
+			elem.Div(
+				vecty.Markup(
+					vecty.Class("form-group"),
+				),
+				elem.Label(
+					vecty.Markup(
+						vecty.Property("for", "load-package-path-input"),
+						vecty.Class("col-form-label"),
+					),
+					vecty.Text("Path"),
+				),
+				v.path,
+				elem.Small(
+					vecty.Markup(
+						vecty.Class("form-text", "text-muted"),
+					),
+					vecty.Text("Load any package, optionally at a version. Leave empty to load the selected import. Versions required by go.mod are used unless a version is given."),
+				),
+			),
 			elem.Div(
 				vecty.Markup(
 					vecty.Class("form-check"),
@@ -152,9 +180,13 @@ func (v *LoadPackageModal) Render() vecty.ComponentOrHTML {
 }
 
 func (v *LoadPackageModal) action(*vecty.Event) {
-	n := v.imps.Node()
-	i := n.Get("selectedIndex").Int()
-	value := n.Get("options").Index(i).Get("value").String()
+	path := strings.TrimSpace(v.path.Node().Get("value").String())
+	if path == "" {
+		n := v.imps.Node()
+		i := n.Get("selectedIndex").Int()
+		path = n.Get("options").Index(i).Get("value").String()
+	}
+	v.path.Node().Set("value", "")
 	v.app.Dispatch(&actions.ModalClose{Modal: models.LoadPackageModal})
-	v.app.Dispatch(&actions.RequestStart{Type: models.GetRequest, Path: value})
+	v.app.Dispatch(&actions.RequestStart{Type: models.GetRequest, Path: v.app.Module.Pinned(path)})
 }
@@ -34,11 +34,21 @@ func (v *Menu) Render() vecty.ComponentOrHTML {
 		clashWarningDisplay = ""
 	}
 
+	runText := "Run"
+	if v.app.Compile.Compiling() || v.app.Connection.Stoppable() {
+		runText = "Stop"
+	}
+
 	buildTagsText := "Build tags..."
 	if len(v.app.Compile.Tags()) > 0 {
 		buildTagsText = fmt.Sprintf("Build tags (%d)...", len(v.app.Compile.Tags()))
 	}
 
+	errorsText := "Errors..."
+	if n := len(v.app.Error.Notifications()); n > 0 {
+		errorsText = fmt.Sprintf("Errors (%d)...", n)
+	}
+
 	return elem.Navigation(
 		vecty.Markup(
 			vecty.Class("menu", "navbar", "navbar-expand", "navbar-light", "bg-light"),
@@ -47,6 +57,7 @@ func (v *Menu) Render() vecty.ComponentOrHTML {
 			vecty.Markup(
 				vecty.Class("navbar-nav", "mr-auto"),
 			),
+			v.renderWorkspaceDropdown(),
 			v.renderPackageDropdown(),
 			v.renderFileDropdown(),
 
@@ -87,6 +98,7 @@ func (v *Menu) Render() vecty.ComponentOrHTML {
 			vecty.Markup(
 				vecty.Class("navbar-nav", "ml-auto"),
 			),
+			vecty.If(v.app.Connection.State() != models.ConnectionIdle, v.renderConnection()),
 			elem.ListItem(
 				vecty.Markup(
 					vecty.Class("nav-item"),
@@ -133,6 +145,7 @@ func (v *Menu) Render() vecty.ComponentOrHTML {
 					),
 				),
 			*/
+			v.renderRunConfigDropdown(),
 			elem.ListItem(
 				vecty.Markup(
 					vecty.Class("nav-item", "btn-group"),
@@ -142,8 +155,8 @@ func (v *Menu) Render() vecty.ComponentOrHTML {
 						vecty.Property("type", "button"),
 						vecty.Class("btn", "btn-primary"),
 						event.Click(func(e *vecty.Event) {
-							if v.app.Connection.Open() || v.app.Compile.Compiling() {
-								return
+							if v.app.Compile.Compiling() || v.app.Connection.Stoppable() {
+								v.app.Dispatch(&actions.Stop{})
 							} else {
 								v.app.Dispatch(&actions.FormatCode{
 									Then: &actions.CompileStart{},
@@ -151,7 +164,7 @@ func (v *Menu) Render() vecty.ComponentOrHTML {
 							}
 						}).PreventDefault(),
 					),
-					vecty.Text("Run"),
+					vecty.Text(runText),
 				),
 				elem.Button(
 					vecty.Markup(
@@ -177,6 +190,64 @@ func (v *Menu) Render() vecty.ComponentOrHTML {
 						),
 						vecty.Text("Format code"),
 					),
+					elem.Anchor(
+						vecty.Markup(
+							vecty.ClassMap{"dropdown-item": true, "disabled": !v.app.Undo.CanUndo()},
+							prop.Href(""),
+							event.Click(func(e *vecty.Event) {
+								v.app.Dispatch(&actions.Undo{})
+							}).PreventDefault(),
+						),
+						vecty.Text("Undo"),
+					),
+					elem.Anchor(
+						vecty.Markup(
+							vecty.ClassMap{"dropdown-item": true, "disabled": !v.app.Undo.CanRedo()},
+							prop.Href(""),
+							event.Click(func(e *vecty.Event) {
+								v.app.Dispatch(&actions.Redo{})
+							}).PreventDefault(),
+						),
+						vecty.Text("Redo"),
+					),
+					elem.Anchor(
+						vecty.Markup(
+							vecty.Class("dropdown-item"),
+							prop.Href(""),
+							event.Click(func(e *vecty.Event) {
+								if v.app.Connection.Stoppable() || v.app.Compile.Compiling() {
+									return
+								}
+								v.app.Dispatch(&actions.FormatCode{
+									Then: &actions.TestStart{Path: v.app.Editor.CurrentPackage()},
+								})
+							}).PreventDefault(),
+						),
+						vecty.Text("Test"),
+					),
+					elem.Anchor(
+						vecty.Markup(
+							vecty.Class("dropdown-item"),
+							vecty.ClassMap{
+								"disabled": !v.app.Compile.Compiled() && !v.app.Compile.Compiling() && !v.app.Connection.Stoppable(),
+							},
+							prop.Href(""),
+							event.Click(func(e *vecty.Event) {
+								v.app.Dispatch(&actions.Stop{})
+							}).PreventDefault(),
+						),
+						vecty.Text("Stop"),
+					),
+					elem.Anchor(
+						vecty.Markup(
+							vecty.Class("dropdown-item"),
+							prop.Href(""),
+							event.Click(func(e *vecty.Event) {
+								v.app.Dispatch(&actions.ModalOpen{Modal: models.FilesModal})
+							}).PreventDefault(),
+						),
+						vecty.Text("Files..."),
+					),
 					elem.Div(
 						vecty.Markup(
 							vecty.Class("dropdown-divider"),
@@ -287,6 +358,36 @@ func (v *Menu) Render() vecty.ComponentOrHTML {
 						),
 						vecty.Text(buildTagsText),
 					),
+					elem.Anchor(
+						vecty.Markup(
+							vecty.Class("dropdown-item"),
+							prop.Href(""),
+							event.Click(func(e *vecty.Event) {
+								v.app.Dispatch(&actions.ModalOpen{Modal: models.ServerModal})
+							}).PreventDefault(),
+						),
+						vecty.Text("Server..."),
+					),
+					elem.Anchor(
+						vecty.Markup(
+							vecty.Class("dropdown-item"),
+							prop.Href(""),
+							event.Click(func(e *vecty.Event) {
+								v.app.Dispatch(&actions.ModalOpen{Modal: models.ModuleModal})
+							}).PreventDefault(),
+						),
+						vecty.Text("Module..."),
+					),
+					elem.Anchor(
+						vecty.Markup(
+							vecty.Class("dropdown-item"),
+							prop.Href(""),
+							event.Click(func(e *vecty.Event) {
+								v.app.Dispatch(&actions.ModalOpen{Modal: models.ErrorsModal})
+							}).PreventDefault(),
+						),
+						vecty.Text(errorsText),
+					),
 					elem.Div(
 						vecty.Markup(
 							vecty.Class("dropdown-divider"),
@@ -515,3 +616,278 @@ func (v *Menu) renderPackageDropdown() *vecty.HTML {
 		),
 	)
 }
+
+func (v *Menu) renderWorkspaceDropdown() *vecty.HTML {
+	var workspaceItems []vecty.MarkupOrChild
+	workspaceItems = append(workspaceItems,
+		vecty.Markup(
+			vecty.Class("dropdown-menu"),
+			vecty.Property("aria-labelledby", "workspaceDropdown"),
+		),
+	)
+	for _, w := range v.app.Workspace.Workspaces() {
+		id := w.ID
+		workspaceItems = append(workspaceItems,
+			elem.Anchor(
+				vecty.Markup(
+					vecty.Class("dropdown-item"),
+					vecty.ClassMap{
+						"disabled": id == v.app.Workspace.Current(),
+					},
+					prop.Href(""),
+					event.Click(func(e *vecty.Event) {
+						v.app.Dispatch(&actions.SwitchWorkspace{
+							ID: id,
+						})
+					}).PreventDefault(),
+				),
+				vecty.Text(w.Name),
+			),
+		)
+	}
+	if len(v.app.Workspace.Workspaces()) > 0 {
+		workspaceItems = append(workspaceItems,
+			elem.Div(
+				vecty.Markup(
+					vecty.Class("dropdown-divider"),
+				),
+			),
+		)
+	}
+	if v.app.Workspace.Conflict() {
+		workspaceItems = append(workspaceItems,
+			elem.Anchor(
+				vecty.Markup(
+					vecty.Class("dropdown-item", "text-danger"),
+					prop.Href(""),
+					event.Click(func(e *vecty.Event) {
+						v.app.Dispatch(&actions.ModalOpen{Modal: models.WorkspaceConflictModal})
+					}).PreventDefault(),
+				),
+				vecty.Text("Changed in another tab..."),
+			),
+		)
+	}
+	workspaceItems = append(workspaceItems,
+		elem.Anchor(
+			vecty.Markup(
+				vecty.Class("dropdown-item"),
+				prop.Href(""),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.ModalOpen{Modal: models.CreateWorkspaceModal})
+				}).PreventDefault(),
+			),
+			vecty.Text("New workspace"),
+		),
+		elem.Anchor(
+			vecty.Markup(
+				vecty.Class("dropdown-item"),
+				prop.Href(""),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.ModalOpen{Modal: models.RenameWorkspaceModal})
+				}).PreventDefault(),
+			),
+			vecty.Text("Rename workspace"),
+		),
+		elem.Anchor(
+			vecty.Markup(
+				vecty.Class("dropdown-item"),
+				prop.Href(""),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.ModalOpen{Modal: models.DuplicateWorkspaceModal})
+				}).PreventDefault(),
+			),
+			vecty.Text("Duplicate workspace"),
+		),
+		elem.Anchor(
+			vecty.Markup(
+				vecty.Class("dropdown-item"),
+				vecty.ClassMap{
+					"disabled": len(v.app.Workspace.Workspaces()) == 0,
+				},
+				prop.Href(""),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.ModalOpen{Modal: models.DeleteWorkspaceModal})
+				}).PreventDefault(),
+			),
+			vecty.Text("Delete workspace"),
+		),
+	)
+
+	name := v.app.Workspace.Name()
+	if name == "" {
+		name = "Workspace"
+	}
+	if v.app.Workspace.Conflict() {
+		name += " (not saved)"
+	}
+
+	return elem.ListItem(
+		vecty.Markup(
+			vecty.Class("nav-item", "dropdown"),
+		),
+		elem.Anchor(
+			vecty.Markup(
+				prop.ID("workspaceDropdown"),
+				prop.Href(""),
+				vecty.Class("nav-link", "dropdown-toggle"),
+				vecty.Property("role", "button"),
+				vecty.Data("toggle", "dropdown"),
+				vecty.Property("aria-haspopup", "true"),
+				vecty.Property("aria-expanded", "false"),
+				event.Click(func(ev *vecty.Event) {}).PreventDefault(),
+			),
+			vecty.Text(name),
+		),
+		elem.Div(
+			workspaceItems...,
+		),
+	)
+}
+
+func (v *Menu) renderRunConfigDropdown() *vecty.HTML {
+	selected := v.app.Compile.Selected()
+	items := []vecty.MarkupOrChild{
+		vecty.Markup(
+			vecty.Class("dropdown-menu", "dropdown-menu-right"),
+			vecty.Property("aria-labelledby", "runConfigDropdown"),
+		),
+		elem.Anchor(
+			vecty.Markup(
+				vecty.Class("dropdown-item"),
+				vecty.ClassMap{
+					"disabled": selected == "",
+				},
+				prop.Href(""),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.SelectRunConfig{Name: ""})
+				}).PreventDefault(),
+			),
+			vecty.Text("Current package"),
+		),
+	}
+	for _, c := range v.app.Compile.RunConfigs() {
+		name := c.Name
+		text := name
+		if c.Target == models.WasmTarget {
+			text += " (wasm)"
+		}
+		items = append(items,
+			elem.Anchor(
+				vecty.Markup(
+					vecty.Class("dropdown-item"),
+					vecty.ClassMap{
+						"disabled": name == selected,
+					},
+					prop.Href(""),
+					event.Click(func(e *vecty.Event) {
+						v.app.Dispatch(&actions.SelectRunConfig{Name: name})
+					}).PreventDefault(),
+				),
+				vecty.Text(text),
+			),
+		)
+	}
+	items = append(items,
+		elem.Div(
+			vecty.Markup(
+				vecty.Class("dropdown-divider"),
+			),
+		),
+		elem.Anchor(
+			vecty.Markup(
+				vecty.Class("dropdown-item"),
+				prop.Href(""),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.ModalOpen{Modal: models.CreateRunConfigModal})
+				}).PreventDefault(),
+			),
+			vecty.Text("New run configuration"),
+		),
+		elem.Anchor(
+			vecty.Markup(
+				vecty.Class("dropdown-item"),
+				vecty.ClassMap{
+					"disabled": selected == "",
+				},
+				prop.Href(""),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.ModalOpen{Modal: models.EditRunConfigModal})
+				}).PreventDefault(),
+			),
+			vecty.Text("Edit run configuration"),
+		),
+		elem.Anchor(
+			vecty.Markup(
+				vecty.Class("dropdown-item"),
+				vecty.ClassMap{
+					"disabled": selected == "",
+				},
+				prop.Href(""),
+				event.Click(func(e *vecty.Event) {
+					var configs []models.RunConfig
+					for _, c := range v.app.Compile.RunConfigs() {
+						if c.Name != selected {
+							configs = append(configs, c)
+						}
+					}
+					v.app.Dispatch(&actions.RunConfigs{Configs: configs})
+				}).PreventDefault(),
+			),
+			vecty.Text("Delete run configuration"),
+		),
+	)
+
+	name := selected
+	if name == "" {
+		name = "Current package"
+	}
+
+	return elem.ListItem(
+		vecty.Markup(
+			vecty.Class("nav-item", "dropdown"),
+			vecty.Style("margin-right", "10px"),
+		),
+		elem.Anchor(
+			vecty.Markup(
+				prop.ID("runConfigDropdown"),
+				prop.Href(""),
+				vecty.Class("nav-link", "dropdown-toggle"),
+				vecty.Property("role", "button"),
+				vecty.Data("toggle", "dropdown"),
+				vecty.Property("aria-haspopup", "true"),
+				vecty.Property("aria-expanded", "false"),
+				event.Click(func(ev *vecty.Event) {}).PreventDefault(),
+			),
+			vecty.Text(name),
+		),
+		elem.Div(items...),
+	)
+}
+
+// renderConnection shows the state of the connection to the server while it's in use
+func (v *Menu) renderConnection() *vecty.HTML {
+	var text string
+	switch v.app.Connection.State() {
+	case models.ConnectionConnecting:
+		text = "connecting"
+	case models.ConnectionOpen:
+		text = "connected"
+	case models.ConnectionRetrying:
+		text = fmt.Sprintf("reconnecting (%d)", v.app.Connection.Attempt())
+	case models.ConnectionFailed:
+		text = "offline"
+	}
+	return elem.ListItem(
+		vecty.Markup(
+			vecty.Class("nav-item"),
+		),
+		elem.Span(
+			vecty.Markup(
+				vecty.Class("navbar-text", "connection-state", "connection-"+string(v.app.Connection.State())),
+				vecty.Style("margin-right", "10px"),
+			),
+			vecty.Text("● "+text),
+		),
+	)
+}
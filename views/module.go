@@ -0,0 +1,98 @@
+package views
+
+import (
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+)
+
+type ModuleModal struct {
+	*Modal
+}
+
+func NewModuleModal(app *stores.App) *ModuleModal {
+	v := &ModuleModal{
+		&Modal{
+			app:   app,
+			id:    models.ModuleModal,
+			title: "Module",
+			large: true,
+		},
+	}
+	return v
+}
+
+func (v *ModuleModal) Render() vecty.ComponentOrHTML {
+	if err := v.app.Module.Err(); err != nil {
+		return v.Body(
+			elem.Paragraph(
+				vecty.Markup(vecty.Class("text-danger")),
+				vecty.Text("Error parsing go.mod: "+err.Error()),
+			),
+		).Build()
+	}
+	m := v.app.Module.Module()
+	if m == nil {
+		return v.Body(
+			elem.Paragraph(
+				vecty.Text("The project has no go.mod file. Use the Add file option to add a go.mod file to a package, and the versions of the required modules will be used when loading and compiling dependencies."),
+			),
+		).Build()
+	}
+
+	rows := []vecty.MarkupOrChild{}
+	for _, r := range m.Require {
+		version := r.Version
+		if r.Indirect {
+			version += " (indirect)"
+		}
+		rows = append(rows, elem.TableRow(
+			elem.TableData(vecty.Text(r.Path)),
+			elem.TableData(vecty.Text(version)),
+		))
+	}
+	if len(m.Require) == 0 {
+		rows = append(rows, elem.TableRow(
+			elem.TableData(
+				vecty.Markup(vecty.Attribute("colspan", "2"), vecty.Class("text-muted")),
+				vecty.Text("No requirements"),
+			),
+		))
+	}
+
+	goVersion := m.Go
+	if goVersion == "" {
+		goVersion = "-"
+	}
+
+	return v.Body(
+		elem.Table(
+			vecty.Markup(vecty.Class("table", "table-sm")),
+			elem.TableBody(
+				elem.TableRow(
+					elem.TableHeader(vecty.Text("Module")),
+					elem.TableData(vecty.Text(m.Path)),
+				),
+				elem.TableRow(
+					elem.TableHeader(vecty.Text("Go")),
+					elem.TableData(vecty.Text(goVersion)),
+				),
+				elem.TableRow(
+					elem.TableHeader(vecty.Text("Package")),
+					elem.TableData(vecty.Text(m.Package)),
+				),
+			),
+		),
+		elem.Table(
+			vecty.Markup(vecty.Class("table", "table-sm")),
+			elem.TableHead(
+				elem.TableRow(
+					elem.TableHeader(vecty.Text("Requirement")),
+					elem.TableHeader(vecty.Text("Version")),
+				),
+			),
+			elem.TableBody(rows...),
+		),
+	).Build()
+}
@@ -1,7 +1,11 @@
 package views
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/dave/dropper"
+	"github.com/dave/flux"
 	"github.com/dave/jsgo/config"
 	"github.com/dave/play/actions"
 	"github.com/dave/play/models"
@@ -83,6 +87,50 @@ func (v *Page) Mount() {
 		}
 	}()
 
+	// Undo and redo apply to the whole project, so they replace the editor's own undo
+	dom.GetWindow().AddEventListener("keydown", true, func(e dom.Event) {
+		ke, ok := e.(*dom.KeyboardEvent)
+		if !ok || !(ke.CtrlKey || ke.MetaKey) || ke.AltKey {
+			return
+		}
+		if target := e.Target(); target != nil {
+			tag := target.TagName()
+			if (tag == "INPUT" || tag == "TEXTAREA") && !target.Class().Contains("ace_text-input") {
+				// native undo in form fields
+				return
+			}
+		}
+		var action flux.ActionInterface
+		switch key := strings.ToLower(ke.Key); {
+		case key == "z" && !ke.ShiftKey:
+			action = &actions.Undo{}
+		case key == "z" && ke.ShiftKey, key == "y":
+			action = &actions.Redo{}
+		default:
+			return
+		}
+		e.PreventDefault()
+		e.StopPropagation()
+		v.app.Dispatch(action)
+	})
+
+	// links to the Go source in stack traces in the console
+	dom.GetWindow().Document().GetElementByID("console").AddEventListener("click", false, func(e dom.Event) {
+		link := e.Target()
+		if link == nil || !link.Class().Contains("source-link") {
+			return
+		}
+		e.PreventDefault()
+		line, _ := strconv.Atoi(link.GetAttribute("data-line"))
+		column, _ := strconv.Atoi(link.GetAttribute("data-column"))
+		v.app.Dispatch(&actions.ChangeFile{
+			Path:   link.GetAttribute("data-path"),
+			Name:   link.GetAttribute("data-file"),
+			Line:   line,
+			Column: column,
+		})
+	})
+
 }
 
 func (v *Page) Unmount() {
@@ -105,6 +153,48 @@ const Styles = `
 		flex: 1;
 		width: 100%;
 	}
+	.problems {
+		max-height: 25%;
+		overflow: auto;
+		border-top: 1px solid #ddd;
+		font-size: 0.8em;
+	}
+	.problems-header {
+		padding: 2px 5px;
+		background-color: #f8f9fa;
+	}
+	.problems ul {
+		margin: 0;
+		padding: 2px 5px;
+	}
+	.notifications {
+		position: fixed;
+		left: 10px;
+		bottom: 10px;
+		width: 400px;
+		max-width: calc(100% - 20px);
+		z-index: 1030;
+	}
+	.notifications .alert {
+		margin-bottom: 5px;
+		word-wrap: break-word;
+	}
+	.connection-state {
+		font-size: 0.8em;
+	}
+	.connection-connecting, .connection-retrying {
+		color: #ffc107 !important;
+	}
+	.connection-open {
+		color: #28a745 !important;
+	}
+	.connection-failed {
+		color: #dc3545 !important;
+	}
+	.ace-error-marker {
+		position: absolute;
+		border-bottom: 2px dotted #dc3545;
+	}
 	.empty-panel {
 		display: flex;
 		align-items: center;
@@ -146,7 +236,66 @@ const Styles = `
 	}
 	#console {
 		padding:5px;
+		margin-bottom: 0;
+	}
+	.console-toolbar {
+		position: sticky;
+		top: 0;
+		padding: 5px;
+		background-color: #f8f9fa;
+		border-bottom: 1px solid #dee2e6;
+	}
+	.console-line {
+		min-height: 1.2em;
+	}
+	.stderr {
+		color: #c0392b;
+	}
+	.stdin {
+		color: #2472c8;
+	}
+	.console-stdin {
+		position: sticky;
+		bottom: 0;
+		padding: 5px;
+		background-color: #f8f9fa;
+		border-top: 1px solid #dee2e6;
 	}
+	.ansi-bold { font-weight: bold; }
+	.ansi-italic { font-style: italic; }
+	.ansi-underline { text-decoration: underline; }
+	.ansi-fg-0 { color: #000000; }
+	.ansi-fg-1 { color: #cd3131; }
+	.ansi-fg-2 { color: #0dbc79; }
+	.ansi-fg-3 { color: #949800; }
+	.ansi-fg-4 { color: #0451a5; }
+	.ansi-fg-5 { color: #bc05bc; }
+	.ansi-fg-6 { color: #0598bc; }
+	.ansi-fg-7 { color: #555555; }
+	.ansi-fg-8 { color: #666666; }
+	.ansi-fg-9 { color: #cd3131; }
+	.ansi-fg-10 { color: #14ce14; }
+	.ansi-fg-11 { color: #b5ba00; }
+	.ansi-fg-12 { color: #0451a5; }
+	.ansi-fg-13 { color: #bc05bc; }
+	.ansi-fg-14 { color: #0598bc; }
+	.ansi-fg-15 { color: #a5a5a5; }
+	.ansi-bg-0 { background-color: #000000; }
+	.ansi-bg-1 { background-color: #cd3131; }
+	.ansi-bg-2 { background-color: #0dbc79; }
+	.ansi-bg-3 { background-color: #e5e510; }
+	.ansi-bg-4 { background-color: #2472c8; }
+	.ansi-bg-5 { background-color: #bc3fbc; }
+	.ansi-bg-6 { background-color: #11a8cd; }
+	.ansi-bg-7 { background-color: #e5e5e5; }
+	.ansi-bg-8 { background-color: #666666; }
+	.ansi-bg-9 { background-color: #f14c4c; }
+	.ansi-bg-10 { background-color: #23d18b; }
+	.ansi-bg-11 { background-color: #f5f543; }
+	.ansi-bg-12 { background-color: #3b8eea; }
+	.ansi-bg-13 { background-color: #d670d6; }
+	.ansi-bg-14 { background-color: #29b8db; }
+	.ansi-bg-15 { background-color: #ffffff; }
 	.octicon {
 		display: inline-block;
 		vertical-align: text-top;
@@ -210,6 +359,7 @@ func (v *Page) Render() vecty.ComponentOrHTML {
 			v.renderLeft(),
 			v.renderRight(),
 		),
+		NewNotifications(v.app),
 		NewAddFileModal(v.app),
 		NewDeleteFileModal(v.app),
 		NewAddPackageModal(v.app),
@@ -218,6 +368,17 @@ func (v *Page) Render() vecty.ComponentOrHTML {
 		NewLoadPackageModal(v.app),
 		NewClashWarningModal(v.app),
 		NewBuildTagsModal(v.app),
+		NewServerModal(v.app),
+		NewModuleModal(v.app),
+		NewFilesModal(v.app),
+		NewErrorsModal(v.app),
+		NewCreateRunConfigModal(v.app),
+		NewEditRunConfigModal(v.app),
+		NewCreateWorkspaceModal(v.app),
+		NewRenameWorkspaceModal(v.app),
+		NewDuplicateWorkspaceModal(v.app),
+		NewDeleteWorkspaceModal(v.app),
+		NewWorkspaceConflictModal(v.app),
 		NewHelpModal(v.app),
 		elem.Anchor(
 			vecty.Markup(
@@ -265,6 +426,7 @@ func (v *Page) renderLeft() *vecty.HTML {
 		),
 		NewMenu(v.app),
 		v.editor,
+		NewProblems(v.app),
 		elem.Div(
 			vecty.Markup(
 				vecty.Class("empty-panel"),
@@ -303,10 +465,6 @@ func (v *Page) renderLeft() *vecty.HTML {
 }
 
 func (v *Page) renderRight() *vecty.HTML {
-	consoleDisplay := ""
-	if !v.app.Page.Console() {
-		consoleDisplay = "none"
-	}
 	return elem.Div(
 		vecty.Markup(
 			prop.ID("right"),
@@ -317,16 +475,6 @@ func (v *Page) renderRight() *vecty.HTML {
 				prop.ID("iframe-holder"),
 			),
 		),
-		elem.Div(
-			vecty.Markup(
-				prop.ID("console-holder"),
-				vecty.Style("display", consoleDisplay),
-			),
-			elem.Preformatted(
-				vecty.Markup(
-					prop.ID("console"),
-				),
-			),
-		),
+		NewConsole(v.app),
 	)
 }
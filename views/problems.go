@@ -0,0 +1,107 @@
+package views
+
+import (
+	"fmt"
+
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/gopherjs/vecty/prop"
+)
+
+type Problems struct {
+	vecty.Core
+	app *stores.App
+}
+
+func NewProblems(app *stores.App) *Problems {
+	v := &Problems{
+		app: app,
+	}
+	return v
+}
+
+func (v *Problems) Render() vecty.ComponentOrHTML {
+	diagnostics := v.app.Diagnostic.Diagnostics()
+
+	display := ""
+	if len(diagnostics) == 0 {
+		display = "none"
+	}
+
+	items := []vecty.MarkupOrChild{
+		vecty.Markup(
+			vecty.Class("list-unstyled"),
+		),
+	}
+	for _, d := range diagnostics {
+		items = append(items, elem.ListItem(
+			v.renderLocation(d),
+			vecty.Text(" "+d.Message),
+		))
+	}
+
+	return elem.Div(
+		vecty.Markup(
+			prop.ID("problems"),
+			vecty.Class("problems"),
+			vecty.Style("display", display),
+		),
+		elem.Div(
+			vecty.Markup(
+				vecty.Class("problems-header"),
+			),
+			vecty.Text(fmt.Sprintf("Problems (%d)", len(diagnostics))),
+			elem.Button(
+				vecty.Markup(
+					prop.Type(prop.TypeButton),
+					vecty.Class("close"),
+					vecty.Property("aria-label", "Close"),
+					event.Click(func(e *vecty.Event) {
+						v.app.Dispatch(&actions.ClearDiagnostics{})
+					}).PreventDefault(),
+				),
+				elem.Span(
+					vecty.Markup(
+						vecty.Property("aria-hidden", "true"),
+					),
+					vecty.Text("×"),
+				),
+			),
+		),
+		elem.UnorderedList(items...),
+	)
+}
+
+func (v *Problems) renderLocation(d models.Diagnostic) *vecty.HTML {
+	location := d.Path
+	if d.File != "" {
+		location = fmt.Sprintf("%s/%s", d.Path, d.File)
+	}
+	if d.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, d.Line)
+	}
+	if d.Column > 0 {
+		location = fmt.Sprintf("%s:%d", location, d.Column)
+	}
+	if !v.app.Source.HasFile(d.Path, d.File) {
+		return elem.Strong(vecty.Text(location + ":"))
+	}
+	return elem.Anchor(
+		vecty.Markup(
+			prop.Href(""),
+			event.Click(func(e *vecty.Event) {
+				v.app.Dispatch(&actions.ChangeFile{
+					Path:   d.Path,
+					Name:   d.File,
+					Line:   d.Line,
+					Column: d.Column,
+				})
+			}).PreventDefault(),
+		),
+		vecty.Text(location+":"),
+	)
+}
@@ -0,0 +1,271 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores"
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/prop"
+)
+
+// RunConfigModal edits a run configuration. It's used to create a configuration and to edit the
+// selected one.
+type RunConfigModal struct {
+	*Modal
+	create bool
+
+	name, path, target, tags, args, env, minify *vecty.HTML
+}
+
+func NewCreateRunConfigModal(app *stores.App) *RunConfigModal {
+	return newRunConfigModal(app, models.CreateRunConfigModal, "New run configuration", true, func() models.RunConfig {
+		path, _ := app.Scanner.Main()
+		return models.RunConfig{Path: path, Tags: app.Compile.Tags(), Minify: app.Page.Minify()}
+	})
+}
+
+func NewEditRunConfigModal(app *stores.App) *RunConfigModal {
+	return newRunConfigModal(app, models.EditRunConfigModal, "Edit run configuration", false, func() models.RunConfig {
+		config, _ := app.Compile.RunConfig()
+		return config
+	})
+}
+
+func newRunConfigModal(app *stores.App, id models.Modal, title string, create bool, initial func() models.RunConfig) *RunConfigModal {
+	v := &RunConfigModal{create: create}
+	v.Modal = &Modal{
+		app:    app,
+		id:     id,
+		title:  title,
+		action: v.action,
+		shown: func() {
+			config := initial()
+			js.Global.Call("$", "#"+string(id)+"-name").Call("val", config.Name)
+			js.Global.Call("$", "#"+string(id)+"-path").Call("val", config.Path)
+			js.Global.Call("$", "#"+string(id)+"-target").Call("val", config.Target)
+			js.Global.Call("$", "#"+string(id)+"-tags").Call("val", strings.Join(config.Tags, " "))
+			js.Global.Call("$", "#"+string(id)+"-args").Call("val", joinArgs(config.Args))
+			js.Global.Call("$", "#"+string(id)+"-env").Call("val", strings.Join(config.Env, "\n"))
+			js.Global.Call("$", "#"+string(id)+"-minify").Call("prop", "checked", config.Minify)
+			js.Global.Call("$", "#"+string(id)+"-name").Call("focus")
+		},
+	}
+	return v
+}
+
+func (v *RunConfigModal) Render() vecty.ComponentOrHTML {
+	id := string(v.id)
+
+	var paths []string
+	for path := range v.app.Scanner.MainPackages() {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	options := []vecty.MarkupOrChild{
+		vecty.Markup(
+			vecty.Class("form-control"),
+			prop.ID(id+"-path"),
+		),
+	}
+	for _, path := range paths {
+		options = append(options,
+			elem.Option(
+				vecty.Markup(
+					prop.Value(path),
+				),
+				vecty.Text(v.app.Scanner.DisplayPath(path)),
+			),
+		)
+	}
+
+	v.name = elem.Input(vecty.Markup(
+		vecty.Class("form-control"),
+		prop.Type(prop.TypeText),
+		prop.ID(id+"-name"),
+	))
+	v.path = elem.Select(options...)
+	v.target = elem.Select(
+		vecty.Markup(
+			vecty.Class("form-control"),
+			prop.ID(id+"-target"),
+		),
+		elem.Option(
+			vecty.Markup(
+				prop.Value(models.GopherJSTarget),
+			),
+			vecty.Text("GopherJS"),
+		),
+		elem.Option(
+			vecty.Markup(
+				prop.Value(models.WasmTarget),
+			),
+			vecty.Text("WebAssembly"),
+		),
+	)
+	v.tags = elem.Input(vecty.Markup(
+		vecty.Class("form-control"),
+		prop.Type(prop.TypeText),
+		prop.ID(id+"-tags"),
+	))
+	v.args = elem.Input(vecty.Markup(
+		vecty.Class("form-control"),
+		prop.Type(prop.TypeText),
+		prop.ID(id+"-args"),
+	))
+	v.env = elem.TextArea(vecty.Markup(
+		vecty.Class("form-control"),
+		prop.ID(id+"-env"),
+		vecty.Property("rows", 3),
+	))
+	v.minify = elem.Input(vecty.Markup(
+		vecty.Class("form-check-input"),
+		prop.Type(prop.TypeCheckbox),
+		prop.ID(id+"-minify"),
+	))
+
+	field := func(name, label, help string, input *vecty.HTML) *vecty.HTML {
+		return elem.Div(
+			vecty.Markup(
+				vecty.Class("form-group"),
+			),
+			elem.Label(
+				vecty.Markup(
+					vecty.Property("for", id+"-"+name),
+					vecty.Class("col-form-label"),
+				),
+				vecty.Text(label),
+			),
+			input,
+			vecty.If(help != "", elem.Small(
+				vecty.Markup(
+					vecty.Class("form-text", "text-muted"),
+				),
+				vecty.Text(help),
+			)),
+		)
+	}
+
+	return v.Body(
+		elem.Form(
+			field("name", "Name", "", v.name),
+			field("path", "Main package", "", v.path),
+			field("target", "Target", "WebAssembly is compiled by the go command on a self-hosted server (see the Server option).", v.target),
+			field("tags", "Build tags", "", v.tags),
+			field("args", "Arguments", `Separated by spaces. Use quotes for arguments containing spaces, e.g. -name "Hello World".`, v.args),
+			field("env", "Environment variables", "One per line, e.g. NAME=value.", v.env),
+			elem.Div(
+				vecty.Markup(
+					vecty.Class("form-check"),
+				),
+				v.minify,
+				elem.Label(
+					vecty.Markup(
+						vecty.Class("form-check-label"),
+						prop.For(id+"-minify"),
+					),
+					vecty.Text("Minify JS"),
+				),
+			),
+		),
+	).Build()
+}
+
+func (v *RunConfigModal) action(*vecty.Event) {
+	config := models.RunConfig{
+		Name:   strings.TrimSpace(v.name.Node().Get("value").String()),
+		Path:   v.path.Node().Get("value").String(),
+		Target: v.target.Node().Get("value").String(),
+		Tags:   strings.Fields(v.tags.Node().Get("value").String()),
+		Args:   splitArgs(v.args.Node().Get("value").String()),
+		Minify: v.minify.Node().Get("checked").Bool(),
+	}
+	for _, line := range strings.Split(v.env.Node().Get("value").String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			config.Env = append(config.Env, line)
+		}
+	}
+	if config.Path == "" {
+		v.app.Fail(models.ProjectSource, errors.New("project has no main package"))
+		return
+	}
+	if config.Name == "" {
+		config.Name = config.Path
+	}
+
+	previous := ""
+	if !v.create {
+		previous = v.app.Compile.Selected()
+	}
+	var configs []models.RunConfig
+	names := map[string]bool{}
+	for _, c := range v.app.Compile.RunConfigs() {
+		if c.Name != previous {
+			configs = append(configs, c)
+			names[c.Name] = true
+		}
+	}
+	// names must be unique
+	name := config.Name
+	for i := 2; names[config.Name]; i++ {
+		config.Name = fmt.Sprintf("%s (%d)", name, i)
+	}
+	configs = append(configs, config)
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+
+	v.app.Dispatch(&actions.ModalClose{Modal: v.id})
+	v.app.Dispatch(&actions.RunConfigs{Configs: configs, Selected: config.Name})
+}
+
+// splitArgs splits s into arguments at spaces. Single or double quotes group an argument that
+// contains spaces.
+func splitArgs(s string) []string {
+	var args []string
+	var arg []rune
+	var quote rune
+	var started bool
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg = append(arg, r)
+		case r == '"' || r == '\'':
+			quote = r
+			started = true
+		case r == ' ' || r == '\t':
+			if started {
+				args = append(args, string(arg))
+			}
+			arg, started = nil, false
+		default:
+			arg = append(arg, r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+// joinArgs is the reverse of splitArgs
+func joinArgs(args []string) string {
+	var quoted []string
+	for _, arg := range args {
+		switch {
+		case strings.Contains(arg, `"`):
+			arg = `'` + arg + `'`
+		case arg == "" || strings.ContainsAny(arg, " \t'"):
+			arg = `"` + arg + `"`
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
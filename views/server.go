@@ -0,0 +1,71 @@
+package views
+
+import (
+	"strings"
+
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/prop"
+)
+
+type ServerModal struct {
+	*Modal
+	input *vecty.HTML
+}
+
+func NewServerModal(app *stores.App) *ServerModal {
+	v := &ServerModal{}
+	v.Modal = &Modal{
+		app:    app,
+		id:     models.ServerModal,
+		title:  "Server",
+		action: v.action,
+	}
+	return v
+}
+
+func (v *ServerModal) Render() vecty.ComponentOrHTML {
+	v.input = elem.Input(vecty.Markup(
+		vecty.Class("form-control"),
+		prop.Type(prop.TypeText),
+		prop.ID("server-input"),
+		prop.Placeholder("Default"),
+		prop.Value(v.app.Backend.Url()),
+	))
+
+	return v.Body(
+		elem.Form(
+			elem.Div(
+				vecty.Markup(
+					vecty.Class("form-group"),
+				),
+				elem.Label(
+					vecty.Markup(
+						vecty.Property("for", "server-input"),
+						vecty.Class("col-form-label"),
+					),
+					vecty.Text("Server URL"),
+				),
+				v.input,
+				elem.Small(
+					vecty.Markup(
+						vecty.Class("form-text", "text-muted"),
+					),
+					vecty.Text("The base URL of a self-hosted compile server, e.g. http://localhost:8080. Leave empty to use the server the editor is served from."),
+				),
+			),
+		),
+	).Build()
+}
+
+func (v *ServerModal) action(*vecty.Event) {
+	url := strings.TrimSpace(v.input.Node().Get("value").String())
+	v.app.Dispatch(&actions.ModalClose{Modal: models.ServerModal})
+	// a server from the query parameter is saved when it's confirmed
+	if url != v.app.Backend.Url() || (url != "" && !v.app.Backend.Saved()) {
+		v.app.Dispatch(&actions.ChangeServer{Url: url})
+	}
+}
@@ -0,0 +1,230 @@
+package views
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dave/play/actions"
+	"github.com/dave/play/models"
+	"github.com/dave/play/stores"
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/gopherjs/vecty/prop"
+)
+
+// WorkspaceNameModal asks for the name of a workspace. It's used to create, rename and duplicate
+// workspaces.
+type WorkspaceNameModal struct {
+	*Modal
+	input *vecty.HTML
+	save  func(name string)
+}
+
+func NewCreateWorkspaceModal(app *stores.App) *WorkspaceNameModal {
+	return newWorkspaceNameModal(app, models.CreateWorkspaceModal, "New workspace",
+		func() string { return "" },
+		func(name string) { app.Dispatch(&actions.CreateWorkspace{Name: name}) },
+	)
+}
+
+func NewRenameWorkspaceModal(app *stores.App) *WorkspaceNameModal {
+	return newWorkspaceNameModal(app, models.RenameWorkspaceModal, "Rename workspace",
+		func() string { return app.Workspace.Name() },
+		func(name string) { app.Dispatch(&actions.RenameWorkspace{ID: app.Workspace.Current(), Name: name}) },
+	)
+}
+
+func NewDuplicateWorkspaceModal(app *stores.App) *WorkspaceNameModal {
+	return newWorkspaceNameModal(app, models.DuplicateWorkspaceModal, "Duplicate workspace",
+		func() string { return app.Workspace.Name() + " copy" },
+		func(name string) { app.Dispatch(&actions.DuplicateWorkspace{ID: app.Workspace.Current(), Name: name}) },
+	)
+}
+
+func newWorkspaceNameModal(app *stores.App, id models.Modal, title string, initial func() string, save func(name string)) *WorkspaceNameModal {
+	v := &WorkspaceNameModal{save: save}
+	v.Modal = &Modal{
+		app:    app,
+		id:     id,
+		title:  title,
+		action: v.action,
+		shown: func() {
+			js.Global.Call("$", "#"+string(id)+"-input").Call("focus")
+			js.Global.Call("$", "#"+string(id)+"-input").Call("val", initial())
+		},
+	}
+	return v
+}
+
+func (v *WorkspaceNameModal) Render() vecty.ComponentOrHTML {
+	v.input = elem.Input(
+		vecty.Markup(
+			prop.Type(prop.TypeText),
+			vecty.Class("form-control"),
+			prop.ID(string(v.id)+"-input"),
+			event.KeyPress(func(ev *vecty.Event) {
+				if ev.Get("keyCode").Int() == 13 {
+					ev.Call("preventDefault")
+					v.action(ev)
+				}
+			}),
+		),
+	)
+	return v.Body(
+		elem.Form(
+			elem.Div(
+				vecty.Markup(vecty.Class("form-group")),
+				elem.Label(
+					vecty.Markup(
+						vecty.Property("for", string(v.id)+"-input"),
+						vecty.Class("col-form-label"),
+					),
+					vecty.Text("Name"),
+				),
+				v.input,
+			),
+		),
+	).Build()
+}
+
+func (v *WorkspaceNameModal) action(*vecty.Event) {
+	name := strings.TrimSpace(v.input.Node().Get("value").String())
+	if name == "" {
+		name = "Untitled"
+	}
+	v.app.Dispatch(&actions.ModalClose{Modal: v.id})
+	v.save(name)
+}
+
+type DeleteWorkspaceModal struct {
+	*Modal
+	sel *vecty.HTML
+}
+
+func NewDeleteWorkspaceModal(app *stores.App) *DeleteWorkspaceModal {
+	v := &DeleteWorkspaceModal{}
+	v.Modal = &Modal{
+		app:    app,
+		id:     models.DeleteWorkspaceModal,
+		title:  "Delete workspace",
+		action: v.action,
+	}
+	return v
+}
+
+func (v *DeleteWorkspaceModal) Render() vecty.ComponentOrHTML {
+	items := []vecty.MarkupOrChild{
+		vecty.Markup(
+			vecty.Class("form-control"),
+			prop.ID("delete-workspace-select"),
+		),
+	}
+	for _, w := range v.app.Workspace.Workspaces() {
+		items = append(items,
+			elem.Option(
+				vecty.Markup(
+					prop.Value(w.ID),
+					vecty.Property("selected", v.app.Workspace.Current() == w.ID),
+				),
+				vecty.Text(w.Name),
+			),
+		)
+	}
+	v.sel = elem.Select(items...)
+
+	return v.Body(
+		elem.Form(
+			elem.Div(
+				vecty.Markup(
+					vecty.Class("form-group"),
+				),
+				elem.Label(
+					vecty.Markup(
+						vecty.Property("for", "delete-workspace-select"),
+						vecty.Class("col-form-label"),
+					),
+					vecty.Text("Workspace"),
+				),
+				v.sel,
+				elem.Small(
+					vecty.Markup(
+						vecty.Class("form-text", "text-muted"),
+					),
+					vecty.Text("The workspace is removed from local storage. This can't be undone."),
+				),
+			),
+		),
+	).Build()
+}
+
+func (v *DeleteWorkspaceModal) action(*vecty.Event) {
+	n := v.sel.Node()
+	i := n.Get("selectedIndex").Int()
+	if i < 0 {
+		return
+	}
+	value := n.Get("options").Index(i).Get("value").String()
+	v.app.Dispatch(&actions.ModalClose{Modal: models.DeleteWorkspaceModal})
+	v.app.Dispatch(&actions.DeleteWorkspace{ID: value})
+}
+
+// WorkspaceConflictModal is shown when another tab changes the current workspace.
+type WorkspaceConflictModal struct {
+	*Modal
+}
+
+func NewWorkspaceConflictModal(app *stores.App) *WorkspaceConflictModal {
+	v := &WorkspaceConflictModal{}
+	v.Modal = &Modal{
+		app:    app,
+		id:     models.WorkspaceConflictModal,
+		title:  "Workspace changed",
+		action: nil,
+	}
+	return v
+}
+
+func (v *WorkspaceConflictModal) Render() vecty.ComponentOrHTML {
+	return v.Body(
+		elem.Paragraph(
+			vecty.Text(fmt.Sprintf("%s has been changed in another tab. Changes in this tab won't be saved until you choose:", v.app.Workspace.Name())),
+		),
+		elem.UnorderedList(
+			elem.ListItem(
+				elem.Strong(vecty.Text("Reload")),
+				vecty.Text(" discards the changes in this tab and loads the workspace from the other tab."),
+			),
+			elem.ListItem(
+				elem.Strong(vecty.Text("Fork")),
+				vecty.Text(" saves the project in this tab as a new workspace."),
+			),
+		),
+		elem.Button(
+			vecty.Markup(
+				prop.Type(prop.TypeButton),
+				vecty.Class("btn", "btn-primary", "mr-2"),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.ModalClose{Modal: models.WorkspaceConflictModal})
+					v.app.Dispatch(&actions.ReloadWorkspace{})
+				}).PreventDefault(),
+			),
+			vecty.Text("Reload"),
+		),
+		elem.Button(
+			vecty.Markup(
+				prop.Type(prop.TypeButton),
+				vecty.Class("btn", "btn-primary"),
+				event.Click(func(e *vecty.Event) {
+					v.app.Dispatch(&actions.ModalClose{Modal: models.WorkspaceConflictModal})
+					v.app.Dispatch(&actions.DuplicateWorkspace{
+						ID:   v.app.Workspace.Current(),
+						Name: v.app.Workspace.Name() + " (fork)",
+					})
+				}).PreventDefault(),
+			),
+			vecty.Text("Fork"),
+		),
+	).Build()
+}